@@ -0,0 +1,175 @@
+package tinvestclient
+
+import (
+	"sync"
+)
+
+const (
+	AliasScopeOperations = "operations"
+	AliasScopePositions  = "positions"
+	// AliasScopeCandles requests the candles of an alias for its source FIGI,
+	// e.g. TCSG candles from the TCS listing in USD
+	AliasScopeCandles = "candles"
+)
+
+// InstrumentAlias ties a dual-listed instrument together: the API reports
+// operations and positions of the listing Alias, traded in Currency, under
+// FIGI. Requests for Alias are sent for FIGI.
+type InstrumentAlias struct {
+	FIGI     string `json:"figi"`
+	Currency string `json:"currency"`
+	Alias    string `json:"alias"`
+	Ticker   string `json:"ticker"`
+}
+
+// AliasRegistry holds the instrument aliases, it is safe for concurrent use
+type AliasRegistry struct {
+	moMutex   sync.RWMutex
+	mtAliases []InstrumentAlias
+}
+
+func NewAliasRegistry(itAliases ...InstrumentAlias) (roRegistry *AliasRegistry) {
+
+	roRegistry = &AliasRegistry{}
+
+	for _, lsAlias := range itAliases {
+		roRegistry.Add(lsAlias)
+	}
+
+	return
+
+}
+
+// DefaultAliasRegistry contains the ruble listing of Tinkoff (TCSG) reported as TCS
+func DefaultAliasRegistry() *AliasRegistry {
+
+	return NewAliasRegistry(InstrumentAlias{
+		FIGI:     FigiTCS,
+		Currency: CurrencyRUB,
+		Alias:    FigiTCSG,
+		Ticker:   TickerTCSG,
+	})
+
+}
+
+// Add registers the alias, replacing one for the same FIGI and currency
+func (r *AliasRegistry) Add(isAlias InstrumentAlias) {
+
+	r.moMutex.Lock()
+	defer r.moMutex.Unlock()
+
+	for lvIndex, lsAlias := range r.mtAliases {
+		if lsAlias.FIGI == isAlias.FIGI && lsAlias.Currency == isAlias.Currency {
+			r.mtAliases[lvIndex] = isAlias
+			return
+		}
+	}
+
+	r.mtAliases = append(r.mtAliases, isAlias)
+
+}
+
+// Remove deletes the aliases of the listing
+func (r *AliasRegistry) Remove(ivAlias string) {
+
+	r.moMutex.Lock()
+	defer r.moMutex.Unlock()
+
+	ltAliases := r.mtAliases[:0]
+
+	for _, lsAlias := range r.mtAliases {
+		if lsAlias.Alias != ivAlias {
+			ltAliases = append(ltAliases, lsAlias)
+		}
+	}
+
+	r.mtAliases = ltAliases
+
+}
+
+func (r *AliasRegistry) Aliases() (rtAliases []InstrumentAlias) {
+
+	r.moMutex.RLock()
+	defer r.moMutex.RUnlock()
+
+	rtAliases = append(rtAliases, r.mtAliases...)
+
+	return
+
+}
+
+// Resolve returns the listing of an instrument reported by the API under the FIGI in the currency
+func (r *AliasRegistry) Resolve(ivFIGI string, ivCurrency string) (rsAlias InstrumentAlias, rvFound bool) {
+
+	r.moMutex.RLock()
+	defer r.moMutex.RUnlock()
+
+	for _, lsAlias := range r.mtAliases {
+		if lsAlias.FIGI == ivFIGI && lsAlias.Currency == ivCurrency {
+			rsAlias = lsAlias
+			rvFound = true
+			return
+		}
+	}
+
+	return
+
+}
+
+// Source returns the FIGI the API knows the listing by, the FIGI itself if it has no alias
+func (r *AliasRegistry) Source(ivFIGI string) string {
+
+	r.moMutex.RLock()
+	defer r.moMutex.RUnlock()
+
+	for _, lsAlias := range r.mtAliases {
+		if lsAlias.Alias == ivFIGI {
+			return lsAlias.FIGI
+		}
+	}
+
+	return ivFIGI
+
+}
+
+// defaultAliasScopes returns the scopes of a registry installed without scopes
+func defaultAliasScopes() map[string]bool {
+
+	return map[string]bool{AliasScopeOperations: true}
+
+}
+
+// aliases returns the registry if aliases apply to the scope
+func (c *Client) aliases(ivScope string) *AliasRegistry {
+
+	if c.moAliases == nil || !c.mtAliasScopes[ivScope] {
+		return nil
+	}
+
+	return c.moAliases
+
+}
+
+// operationsSource returns the FIGI the operations of the listing are requested by
+func (c *Client) operationsSource(ivFIGI string) string {
+
+	if loAliases := c.aliases(AliasScopeOperations); loAliases != nil {
+		return loAliases.Source(ivFIGI)
+	}
+
+	return ivFIGI
+
+}
+
+// operationFIGI returns the listing of an operation reported under the FIGI in the currency
+func (c *Client) operationFIGI(ivFIGI string, ivCurrency string) string {
+
+	if loAliases := c.aliases(AliasScopeOperations); loAliases != nil {
+		if lsAlias, lvFound := loAliases.Resolve(ivFIGI, ivCurrency); lvFound {
+			return lsAlias.Alias
+		}
+	}
+
+	return ivFIGI
+
+}
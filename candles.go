@@ -0,0 +1,231 @@
+package tinvestclient
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CandleProgress is reported after every downloaded window of GetCandleHistory
+type CandleProgress struct {
+	Windows int
+	Done    int
+	Candles int
+	From    time.Time
+	To      time.Time
+}
+
+// CandleHistoryOption configures GetCandleHistory
+type CandleHistoryOption func(*candleHistory)
+
+type candleHistory struct {
+	mvConcurrency int
+	mfProgress    func(CandleProgress)
+}
+
+// WithCandleConcurrency limits the windows downloaded at the same time, the rate limiter applies in addition
+func WithCandleConcurrency(ivConcurrency int) CandleHistoryOption {
+	return func(h *candleHistory) {
+		h.mvConcurrency = ivConcurrency
+	}
+}
+
+func WithCandleProgress(ifProgress func(CandleProgress)) CandleHistoryOption {
+	return func(h *candleHistory) {
+		h.mfProgress = ifProgress
+	}
+}
+
+// candleWindowEnd returns the end of the longest range the API accepts for the interval
+func candleWindowEnd(ivInterval string, ivFrom time.Time) (rvTo time.Time, roError error) {
+
+	switch ivInterval {
+	case IntervalMin1, IntervalMin2, IntervalMin3, IntervalMin5, IntervalMin10, IntervalMin15, IntervalMin30:
+		rvTo = ivFrom.AddDate(0, 0, 1)
+	case IntervalHour:
+		rvTo = ivFrom.AddDate(0, 0, 7)
+	case IntervalDay:
+		rvTo = ivFrom.AddDate(1, 0, 0)
+	case IntervalWeek:
+		rvTo = ivFrom.AddDate(2, 0, 0)
+	case IntervalMonth:
+		rvTo = ivFrom.AddDate(10, 0, 0)
+	default:
+		roError = fmt.Errorf("candle interval %q is not supported", ivInterval)
+	}
+
+	return
+
+}
+
+func (c *Client) GetCandleHistory(ivFIGI string, ivInterval string, ivFrom time.Time, ivTo time.Time, itOptions ...CandleHistoryOption) (rtCandles []Candle, roError error) {
+
+	rtCandles, roError = c.GetCandleHistoryContext(context.Background(), ivFIGI, ivInterval, ivFrom, ivTo, itOptions...)
+
+	return
+
+}
+
+// GetCandleHistoryContext splits the range into the windows the API accepts
+// for the interval, downloads them concurrently and returns one series
+// ordered by time without duplicates
+func (c *Client) GetCandleHistoryContext(ioContext context.Context, ivFIGI string, ivInterval string, ivFrom time.Time, ivTo time.Time, itOptions ...CandleHistoryOption) (rtCandles []Candle, roError error) {
+
+	lsHistory := candleHistory{mvConcurrency: 4}
+
+	for _, lfOption := range itOptions {
+		lfOption(&lsHistory)
+	}
+
+	if lsHistory.mvConcurrency < 1 {
+		lsHistory.mvConcurrency = 1
+	}
+
+	ltWindows, roError := candleWindows(ivInterval, ivFrom, ivTo)
+
+	if roError != nil {
+		return
+	}
+
+	ltResults := make([][]Candle, len(ltWindows))
+
+	loContext, lfCancel := context.WithCancel(ioContext)
+
+	defer lfCancel()
+
+	lcWindows := make(chan int)
+
+	loMutex := sync.Mutex{}
+	loWaitGroup := sync.WaitGroup{}
+
+	lsProgress := CandleProgress{Windows: len(ltWindows)}
+
+	for lvWorker := 0; lvWorker < lsHistory.mvConcurrency && lvWorker < len(ltWindows); lvWorker++ {
+
+		loWaitGroup.Add(1)
+
+		go func() {
+
+			defer loWaitGroup.Done()
+
+			for lvIndex := range lcWindows {
+
+				ltCandles, loError := c.GetCandlesContext(loContext, ivFIGI, ivInterval, ltWindows[lvIndex][0], ltWindows[lvIndex][1])
+
+				loMutex.Lock()
+
+				if loError != nil {
+					if roError == nil {
+						roError = fmt.Errorf("candles from %v to %v: %w", ltWindows[lvIndex][0], ltWindows[lvIndex][1], loError)
+					}
+					lfCancel()
+					loMutex.Unlock()
+					continue
+				}
+
+				ltResults[lvIndex] = ltCandles
+
+				lsProgress.Done++
+				lsProgress.Candles += len(ltCandles)
+				lsProgress.From = ltWindows[lvIndex][0]
+				lsProgress.To = ltWindows[lvIndex][1]
+
+				if lsHistory.mfProgress != nil {
+					lsHistory.mfProgress(lsProgress)
+				}
+
+				loMutex.Unlock()
+
+			}
+
+		}()
+
+	}
+
+	for lvIndex := range ltWindows {
+
+		select {
+		case lcWindows <- lvIndex:
+			continue
+		case <-loContext.Done():
+		}
+
+		break
+
+	}
+
+	close(lcWindows)
+
+	loWaitGroup.Wait()
+
+	if roError == nil && ioContext.Err() != nil {
+		roError = ioContext.Err()
+	}
+
+	if roError != nil {
+		return
+	}
+
+	rtCandles = mergeCandles(ltResults...)
+
+	return
+
+}
+
+// candleWindows splits the range into consecutive windows of the maximum length
+func candleWindows(ivInterval string, ivFrom time.Time, ivTo time.Time) (rtWindows [][2]time.Time, roError error) {
+
+	for lvFrom := ivFrom; lvFrom.Before(ivTo); {
+
+		lvTo, loError := candleWindowEnd(ivInterval, lvFrom)
+
+		if loError != nil {
+			roError = loError
+			return
+		}
+
+		if lvTo.After(ivTo) {
+			lvTo = ivTo
+		}
+
+		rtWindows = append(rtWindows, [2]time.Time{lvFrom, lvTo})
+
+		lvFrom = lvTo
+
+	}
+
+	// An empty range still validates the interval
+	if len(rtWindows) == 0 {
+		_, roError = candleWindowEnd(ivInterval, ivFrom)
+	}
+
+	return
+
+}
+
+// mergeCandles returns the candles ordered by time, the later of equal candles wins
+func mergeCandles(itSeries ...[]Candle) (rtCandles []Candle) {
+
+	ltByTime := map[int64]Candle{}
+
+	for _, ltCandles := range itSeries {
+		for _, lsCandle := range ltCandles {
+			ltByTime[lsCandle.Time.UnixNano()] = lsCandle
+		}
+	}
+
+	rtCandles = make([]Candle, 0, len(ltByTime))
+
+	for _, lsCandle := range ltByTime {
+		rtCandles = append(rtCandles, lsCandle)
+	}
+
+	sort.Slice(rtCandles, func(i, j int) bool {
+		return rtCandles[i].Time.Before(rtCandles[j].Time)
+	})
+
+	return
+
+}
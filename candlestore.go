@@ -0,0 +1,439 @@
+package tinvestclient
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// CandleRange is the time range [From, To)
+type CandleRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// CandleStore keeps candles by FIGI and interval together with the ranges
+// they cover, so a range without candles (weekend, no trades) is known too
+type CandleStore interface {
+	LoadCandles(string, string, time.Time, time.Time) ([]Candle, error)
+	SaveCandles(string, string, CandleRange, []Candle) error
+	CandleRanges(string, string) ([]CandleRange, error)
+}
+
+// FileCandleStore keeps every FIGI and interval in a gzip compressed JSON
+// lines file in the directory: the covered ranges first, then the candles.
+// Saving rewrites the file atomically, it is safe for concurrent use
+// within one process.
+type FileCandleStore struct {
+	mvDir   string
+	moMutex sync.Mutex
+	mtLocks map[string]*sync.RWMutex
+}
+
+type candleFileHeader struct {
+	Ranges []CandleRange `json:"ranges"`
+}
+
+func NewFileCandleStore(ivDir string) (roStore *FileCandleStore, roError error) {
+
+	roError = os.MkdirAll(ivDir, 0700)
+
+	if roError != nil {
+		return
+	}
+
+	roStore = &FileCandleStore{mvDir: ivDir, mtLocks: map[string]*sync.RWMutex{}}
+
+	return
+
+}
+
+func (s *FileCandleStore) lock(ivFIGI string, ivInterval string) (roLock *sync.RWMutex, rvPath string) {
+
+	rvPath = filepath.Join(s.mvDir, fmt.Sprintf("%v_%v.jsonl.gz", ivFIGI, ivInterval))
+
+	s.moMutex.Lock()
+	defer s.moMutex.Unlock()
+
+	roLock = s.mtLocks[rvPath]
+
+	if roLock == nil {
+		roLock = &sync.RWMutex{}
+		s.mtLocks[rvPath] = roLock
+	}
+
+	return
+
+}
+
+func (s *FileCandleStore) LoadCandles(ivFIGI string, ivInterval string, ivFrom time.Time, ivTo time.Time) (rtCandles []Candle, roError error) {
+
+	loLock, lvPath := s.lock(ivFIGI, ivInterval)
+
+	loLock.RLock()
+	defer loLock.RUnlock()
+
+	_, ltCandles, roError := readCandleFile(lvPath)
+
+	if roError != nil {
+		return
+	}
+
+	for _, lsCandle := range ltCandles {
+		if !lsCandle.Time.Before(ivFrom) && lsCandle.Time.Before(ivTo) {
+			rtCandles = append(rtCandles, lsCandle)
+		}
+	}
+
+	return
+
+}
+
+func (s *FileCandleStore) CandleRanges(ivFIGI string, ivInterval string) (rtRanges []CandleRange, roError error) {
+
+	loLock, lvPath := s.lock(ivFIGI, ivInterval)
+
+	loLock.RLock()
+	defer loLock.RUnlock()
+
+	lsHeader, _, roError := readCandleFile(lvPath)
+
+	rtRanges = lsHeader.Ranges
+
+	return
+
+}
+
+// SaveCandles merges the candles into the file, newer candles replace stored ones of the same time
+func (s *FileCandleStore) SaveCandles(ivFIGI string, ivInterval string, isRange CandleRange, itCandles []Candle) (roError error) {
+
+	loLock, lvPath := s.lock(ivFIGI, ivInterval)
+
+	loLock.Lock()
+	defer loLock.Unlock()
+
+	lsHeader, ltCandles, roError := readCandleFile(lvPath)
+
+	if roError != nil {
+		return
+	}
+
+	if isRange.From.Before(isRange.To) {
+		lsHeader.Ranges = mergeCandleRanges(append(lsHeader.Ranges, isRange))
+	}
+
+	roError = writeCandleFile(lvPath, lsHeader, mergeCandles(ltCandles, itCandles))
+
+	return
+
+}
+
+func readCandleFile(ivPath string) (rsHeader candleFileHeader, rtCandles []Candle, roError error) {
+
+	loFile, roError := os.Open(ivPath)
+
+	if os.IsNotExist(roError) {
+		roError = nil
+		return
+	}
+
+	if roError != nil {
+		return
+	}
+
+	defer loFile.Close()
+
+	loReader, roError := gzip.NewReader(loFile)
+
+	if roError != nil {
+		return
+	}
+
+	defer loReader.Close()
+
+	// A decoder, the header line grows with the ranges beyond any line limit
+	loDecoder := json.NewDecoder(loReader)
+
+	roError = loDecoder.Decode(&rsHeader)
+
+	for roError == nil {
+
+		lsCandle := Candle{}
+
+		roError = loDecoder.Decode(&lsCandle)
+
+		if roError == nil {
+			rtCandles = append(rtCandles, lsCandle)
+		}
+
+	}
+
+	if roError == io.EOF {
+		roError = nil
+	}
+
+	return
+
+}
+
+func writeCandleFile(ivPath string, isHeader candleFileHeader, itCandles []Candle) (roError error) {
+
+	loFile, roError := os.CreateTemp(filepath.Dir(ivPath), filepath.Base(ivPath)+".*")
+
+	if roError != nil {
+		return
+	}
+
+	defer os.Remove(loFile.Name())
+
+	loWriter := gzip.NewWriter(loFile)
+	loEncoder := json.NewEncoder(loWriter)
+
+	roError = loEncoder.Encode(isHeader)
+
+	for lvIndex := 0; roError == nil && lvIndex < len(itCandles); lvIndex++ {
+		roError = loEncoder.Encode(itCandles[lvIndex])
+	}
+
+	if roError == nil {
+		roError = loWriter.Close()
+	}
+
+	if roError == nil {
+		roError = loFile.Sync()
+	}
+
+	if loError := loFile.Close(); roError == nil {
+		roError = loError
+	}
+
+	if roError != nil {
+		return
+	}
+
+	roError = os.Rename(loFile.Name(), ivPath)
+
+	return
+
+}
+
+// mergeCandleRanges returns the union of the ranges ordered by time
+func mergeCandleRanges(itRanges []CandleRange) (rtRanges []CandleRange) {
+
+	ltRanges := append([]CandleRange(nil), itRanges...)
+
+	sort.Slice(ltRanges, func(i, j int) bool {
+		return ltRanges[i].From.Before(ltRanges[j].From)
+	})
+
+	for _, lsRange := range ltRanges {
+
+		lvLast := len(rtRanges) - 1
+
+		if lvLast >= 0 && !lsRange.From.After(rtRanges[lvLast].To) {
+			if lsRange.To.After(rtRanges[lvLast].To) {
+				rtRanges[lvLast].To = lsRange.To
+			}
+			continue
+		}
+
+		rtRanges = append(rtRanges, lsRange)
+
+	}
+
+	return
+
+}
+
+// missingCandleRanges returns the parts of [from, to) not covered by the ranges
+func missingCandleRanges(itRanges []CandleRange, ivFrom time.Time, ivTo time.Time) (rtGaps []CandleRange) {
+
+	lvFrom := ivFrom
+
+	for _, lsRange := range mergeCandleRanges(itRanges) {
+
+		if !lsRange.To.After(lvFrom) {
+			continue
+		}
+
+		if !lsRange.From.Before(ivTo) {
+			break
+		}
+
+		if lsRange.From.After(lvFrom) {
+			rtGaps = append(rtGaps, CandleRange{From: lvFrom, To: lsRange.From})
+		}
+
+		lvFrom = lsRange.To
+
+	}
+
+	if lvFrom.Before(ivTo) {
+		rtGaps = append(rtGaps, CandleRange{From: lvFrom, To: ivTo})
+	}
+
+	return
+
+}
+
+// CandleCache serves candles from the store and downloads only the missing ranges
+type CandleCache struct {
+	moClient  *Client
+	moStore   CandleStore
+	moMutex   sync.Mutex
+	mtLocks   map[string]*sync.Mutex
+	mtOptions []CandleHistoryOption
+}
+
+func NewCandleCache(ioClient *Client, ioStore CandleStore, itOptions ...CandleHistoryOption) *CandleCache {
+
+	return &CandleCache{moClient: ioClient, moStore: ioStore, mtLocks: map[string]*sync.Mutex{}, mtOptions: itOptions}
+
+}
+
+func (c *CandleCache) GetCandles(ivFIGI string, ivInterval string, ivFrom time.Time, ivTo time.Time) (rtCandles []Candle, roError error) {
+
+	rtCandles, roError = c.GetCandlesContext(context.Background(), ivFIGI, ivInterval, ivFrom, ivTo)
+
+	return
+
+}
+
+func (c *CandleCache) GetCandlesContext(ioContext context.Context, ivFIGI string, ivInterval string, ivFrom time.Time, ivTo time.Time) (rtCandles []Candle, roError error) {
+
+	roError = c.fill(ioContext, ivFIGI, ivInterval, ivFrom, ivTo)
+
+	if roError != nil {
+		return
+	}
+
+	rtCandles, roError = c.moStore.LoadCandles(ivFIGI, ivInterval, ivFrom, ivTo)
+
+	return
+
+}
+
+// Sync downloads the candles missing from the time up to now
+func (c *CandleCache) Sync(ioContext context.Context, ivFIGI string, ivInterval string, ivFrom time.Time) (roError error) {
+
+	roError = c.fill(ioContext, ivFIGI, ivInterval, ivFrom, time.Now())
+
+	return
+
+}
+
+// Gaps returns the ranges of [from, to) which are not stored yet
+func (c *CandleCache) Gaps(ivFIGI string, ivInterval string, ivFrom time.Time, ivTo time.Time) (rtGaps []CandleRange, roError error) {
+
+	ltRanges, roError := c.moStore.CandleRanges(ivFIGI, ivInterval)
+
+	if roError != nil {
+		return
+	}
+
+	rtGaps = missingCandleRanges(ltRanges, ivFrom, ivTo)
+
+	return
+
+}
+
+func (c *CandleCache) fill(ioContext context.Context, ivFIGI string, ivInterval string, ivFrom time.Time, ivTo time.Time) (roError error) {
+
+	_, roError = candleWindowEnd(ivInterval, ivFrom)
+
+	if roError != nil {
+		return
+	}
+
+	ltGaps, roError := c.Gaps(ivFIGI, ivInterval, ivFrom, ivTo)
+
+	if roError != nil || len(ltGaps) == 0 {
+		return
+	}
+
+	// One download per FIGI and interval, the others wait and find the gaps filled
+	c.moMutex.Lock()
+
+	lvKey := ivFIGI + "_" + ivInterval
+	loLock := c.mtLocks[lvKey]
+
+	if loLock == nil {
+		loLock = &sync.Mutex{}
+		c.mtLocks[lvKey] = loLock
+	}
+
+	c.moMutex.Unlock()
+
+	loLock.Lock()
+	defer loLock.Unlock()
+
+	ltGaps, roError = c.Gaps(ivFIGI, ivInterval, ivFrom, ivTo)
+
+	if roError != nil {
+		return
+	}
+
+	// The current candle is not final, its range stays a gap to be downloaded again
+	lvComplete := time.Now().Add(-candleDuration(ivInterval))
+
+	for _, lsGap := range ltGaps {
+
+		ltCandles, loError := c.moClient.GetCandleHistoryContext(ioContext, ivFIGI, ivInterval, lsGap.From, lsGap.To, c.mtOptions...)
+
+		if loError != nil {
+			roError = loError
+			return
+		}
+
+		if lsGap.To.After(lvComplete) {
+			lsGap.To = lvComplete
+		}
+
+		roError = c.moStore.SaveCandles(ivFIGI, ivInterval, lsGap, ltCandles)
+
+		if roError != nil {
+			return
+		}
+
+	}
+
+	return
+
+}
+
+// candleDuration returns the longest duration of a candle of the interval
+func candleDuration(ivInterval string) time.Duration {
+
+	switch ivInterval {
+	case IntervalMin1:
+		return time.Minute
+	case IntervalMin2:
+		return 2 * time.Minute
+	case IntervalMin3:
+		return 3 * time.Minute
+	case IntervalMin5:
+		return 5 * time.Minute
+	case IntervalMin10:
+		return 10 * time.Minute
+	case IntervalMin15:
+		return 15 * time.Minute
+	case IntervalMin30:
+		return 30 * time.Minute
+	case IntervalHour:
+		return time.Hour
+	case IntervalDay:
+		return 24 * time.Hour
+	case IntervalWeek:
+		return 7 * 24 * time.Hour
+	default:
+		return 31 * 24 * time.Hour
+	}
+
+}
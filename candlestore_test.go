@@ -0,0 +1,146 @@
+package tinvestclient
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testRange(ivFrom int, ivTo int) CandleRange {
+
+	lvBase := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	return CandleRange{From: lvBase.Add(time.Duration(ivFrom) * time.Hour), To: lvBase.Add(time.Duration(ivTo) * time.Hour)}
+
+}
+
+func TestMergeCandleRanges(t *testing.T) {
+
+	ltRanges := mergeCandleRanges([]CandleRange{testRange(10, 12), testRange(0, 2), testRange(1, 3), testRange(3, 4), testRange(6, 8), testRange(11, 11)})
+
+	ltWant := []CandleRange{testRange(0, 4), testRange(6, 8), testRange(10, 12)}
+
+	if !reflect.DeepEqual(ltRanges, ltWant) {
+		t.Errorf("merged %v, want %v", ltRanges, ltWant)
+	}
+
+}
+
+func TestMissingCandleRanges(t *testing.T) {
+
+	ltStored := []CandleRange{testRange(2, 4), testRange(6, 8)}
+
+	ltCases := []struct {
+		Name string
+		From int
+		To   int
+		Gaps []CandleRange
+	}{
+		{"around", 0, 10, []CandleRange{testRange(0, 2), testRange(4, 6), testRange(8, 10)}},
+		{"inside", 2, 4, nil},
+		{"overlapping", 3, 7, []CandleRange{testRange(4, 6)}},
+		{"after", 9, 10, []CandleRange{testRange(9, 10)}},
+	}
+
+	for _, lsCase := range ltCases {
+
+		lsRange := testRange(lsCase.From, lsCase.To)
+
+		if ltGaps := missingCandleRanges(ltStored, lsRange.From, lsRange.To); !reflect.DeepEqual(ltGaps, lsCase.Gaps) {
+			t.Errorf("%v: gaps %v, want %v", lsCase.Name, ltGaps, lsCase.Gaps)
+		}
+
+	}
+
+}
+
+func TestFileCandleStoreRoundTrip(t *testing.T) {
+
+	loStore, loError := NewFileCandleStore(t.TempDir())
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	lsRange := testRange(0, 3)
+
+	ltCandles := []Candle{
+		{Time: lsRange.From.Add(2 * time.Hour), Close: 3},
+		{Time: lsRange.From, Close: 1},
+		{Time: lsRange.From.Add(time.Hour), Close: 2},
+	}
+
+	if loError = loStore.SaveCandles(testFIGI, IntervalHour, lsRange, ltCandles); loError != nil {
+		t.Fatal(loError)
+	}
+
+	// A later download replaces the candle of the same time
+	if loError = loStore.SaveCandles(testFIGI, IntervalHour, testRange(2, 4), []Candle{{Time: lsRange.From.Add(2 * time.Hour), Close: 4}}); loError != nil {
+		t.Fatal(loError)
+	}
+
+	ltLoaded, loError := loStore.LoadCandles(testFIGI, IntervalHour, lsRange.From.Add(time.Hour), lsRange.To)
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	if len(ltLoaded) != 2 || ltLoaded[0].Close != 2 || ltLoaded[1].Close != 4 || !ltLoaded[0].Time.Equal(lsRange.From.Add(time.Hour)) {
+		t.Errorf("loaded %+v, want the candles of 1h and 2h", ltLoaded)
+	}
+
+	ltRanges, loError := loStore.CandleRanges(testFIGI, IntervalHour)
+
+	if loError != nil || !reflect.DeepEqual(ltRanges, []CandleRange{testRange(0, 4)}) {
+		t.Errorf("ranges %v, %v, want one merged range", ltRanges, loError)
+	}
+
+	if ltLoaded, loError = loStore.LoadCandles(testFIGI, IntervalDay, lsRange.From, lsRange.To); loError != nil || len(ltLoaded) != 0 {
+		t.Errorf("loaded %v, %v from an interval never saved", ltLoaded, loError)
+	}
+
+}
+
+func TestFileCandleStoreManyRanges(t *testing.T) {
+
+	loStore, loError := NewFileCandleStore(t.TempDir())
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	// Every second hour only, the ranges do not merge and the header outgrows a scanner line
+	lsHeader := candleFileHeader{}
+	ltCandles := []Candle{}
+
+	for lvHour := 0; lvHour < 10000; lvHour += 2 {
+		lsRange := testRange(lvHour, lvHour+1)
+		lsHeader.Ranges = append(lsHeader.Ranges, lsRange)
+		ltCandles = append(ltCandles, Candle{Time: lsRange.From, Close: float64(lvHour)})
+	}
+
+	_, lvPath := loStore.lock(testFIGI, IntervalHour)
+
+	if loError = writeCandleFile(lvPath, lsHeader, ltCandles); loError != nil {
+		t.Fatal(loError)
+	}
+
+	lsRange := testRange(10000, 10001)
+
+	if loError = loStore.SaveCandles(testFIGI, IntervalHour, lsRange, []Candle{{Time: lsRange.From}}); loError != nil {
+		t.Fatal(loError)
+	}
+
+	ltRanges, loError := loStore.CandleRanges(testFIGI, IntervalHour)
+
+	if loError != nil || len(ltRanges) != 5001 {
+		t.Fatalf("%v ranges, %v, want 5001", len(ltRanges), loError)
+	}
+
+	ltLoaded, loError := loStore.LoadCandles(testFIGI, IntervalHour, testRange(0, 0).From, lsRange.To)
+
+	if loError != nil || len(ltLoaded) != 5001 {
+		t.Errorf("%v candles, %v, want 5001", len(ltLoaded), loError)
+	}
+
+}
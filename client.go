@@ -2,61 +2,109 @@ package tinvestclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
 	"io"
-	"math"
 	"net/http"
 	"net/url"
-	"sort"
+	"strconv"
 	"time"
 )
 
 const (
-	CurrencyRUB               = "RUB"
-	CurrencyUSD               = "USD"
-	CurrencyEUR               = "EUR"
-	IntervalMin1              = "1min"
-	IntervalMin2              = "2min"
-	IntervalMin3              = "3min"
-	IntervalMin5              = "5min"
-	IntervalMin10             = "10min"
-	IntervalMin15             = "15min"
-	IntervalMin30             = "30min"
-	IntervalHour              = "hour"
-	IntervalDay               = "day"
-	IntervalWeek              = "week"
-	IntervalMonth             = "month"
-	InstumentTypeCurrency     = "Currency"
-	InstumentTypeShare        = "Stock"
-	InstumentTypeBond         = "Bond"
-	InstumentTypeETF          = "Etf"
-	CandleTypeGreen           = "Green"
-	CandleTypeRed             = "Red"
-	TickerTCS                 = "TCS"
-	TickerTCSG                = "TCSG"
-	FigiAAPL                  = "BBG000B9XRY4"
-	FigiTCS                   = "BBG005DXJS36"
-	FigiTCSG                  = "BBG00QPYJ5H0"
-	OperationBuy              = "Buy"
-	OperationSell             = "Sell"
-	OperationBuyCard          = "BuyCard"
-	OperationDividend         = "Dividend"
-	OperationTaxDividend      = "TaxDividend"
-	OperationCoupon           = "Coupon"
-	OperationTaxCoupon        = "TaxCoupon"
-	OperationBrokerCommission = "BrokerCommission"
-	statusError               = "Error"
-	statusDone                = "Done"
-	orderTypeLimit            = "limit"
-	orderTypeMarket           = "market"
+	CurrencyRUB                       = "RUB"
+	CurrencyUSD                       = "USD"
+	CurrencyEUR                       = "EUR"
+	IntervalMin1                      = "1min"
+	IntervalMin2                      = "2min"
+	IntervalMin3                      = "3min"
+	IntervalMin5                      = "5min"
+	IntervalMin10                     = "10min"
+	IntervalMin15                     = "15min"
+	IntervalMin30                     = "30min"
+	IntervalHour                      = "hour"
+	IntervalDay                       = "day"
+	IntervalWeek                      = "week"
+	IntervalMonth                     = "month"
+	InstumentTypeCurrency             = "Currency"
+	InstumentTypeShare                = "Stock"
+	InstumentTypeBond                 = "Bond"
+	InstumentTypeETF                  = "Etf"
+	CandleTypeGreen                   = "Green"
+	CandleTypeRed                     = "Red"
+	TickerTCS                         = "TCS"
+	TickerTCSG                        = "TCSG"
+	FigiAAPL                          = "BBG000B9XRY4"
+	FigiTCS                           = "BBG005DXJS36"
+	FigiTCSG                          = "BBG00QPYJ5H0"
+	OperationBuy                      = "Buy"
+	OperationSell                     = "Sell"
+	OperationBuyCard                  = "BuyCard"
+	OperationDividend                 = "Dividend"
+	OperationTaxDividend              = "TaxDividend"
+	OperationCoupon                   = "Coupon"
+	OperationTaxCoupon                = "TaxCoupon"
+	OperationBrokerCommission         = "BrokerCommission"
+	OperationExchangeCommission       = "ExchangeCommission"
+	OperationServiceCommission        = "ServiceCommission"
+	OperationMarginCommission         = "MarginCommission"
+	OperationOtherCommission          = "OtherCommission"
+	OperationPayIn                    = "PayIn"
+	OperationPayOut                   = "PayOut"
+	OperationTax                      = "Tax"
+	OperationTaxLucre                 = "TaxLucre"
+	OperationTaxBack                  = "TaxBack"
+	OperationRepayment                = "Repayment"
+	OperationPartRepayment            = "PartRepayment"
+	OperationSecurityIn               = "SecurityIn"
+	OperationSecurityOut              = "SecurityOut"
+	OperationStatusDone               = "Done"
+	OperationStatusDecline            = "Decline"
+	OperationStatusProgress           = "Progress"
+	statusError                       = "Error"
+	orderTypeLimit                    = "limit"
+	orderTypeMarket                   = "market"
+	OrderStatusNew                    = "New"
+	OrderStatusPartiallyFill          = "PartiallyFill"
+	OrderStatusFill                   = "Fill"
+	OrderStatusCancelled              = "Cancelled"
+	OrderStatusReplaced               = "Replaced"
+	OrderStatusPendingCancel          = "PendingCancel"
+	OrderStatusRejected               = "Rejected"
+	OrderStatusPendingReplace         = "PendingReplace"
+	OrderStatusPendingNew             = "PendingNew"
+	TradeStatusNormalTrading          = "NormalTrading"
+	TradeStatusNotAvailableForTrading = "NotAvailableForTrading"
+)
+
+const (
+	// DefaultURL is the production OpenAPI endpoint
+	DefaultURL = "https://api-invest.tinkoff.ru/openapi/"
+	// StreamingURL is the market data WebSocket endpoint
+	StreamingURL = "wss://api-invest.tinkoff.ru/openapi/md/v1/md-openapi/ws"
+	// SandboxURL is the sandbox OpenAPI endpoint
+	SandboxURL = "https://api-invest.tinkoff.ru/openapi/sandbox/"
+	// DefaultTimeout is applied to every call whose context has no deadline
+	DefaultTimeout = 30 * time.Second
 )
 
 type Client struct {
-	mvUrl     string
-	mvToken   string
-	mvAccount string
+	mvUrl         string
+	mvToken       string
+	mvAccount     string
+	mvTimeout     time.Duration
+	mvUserAgent   string
+	mtHeaders     http.Header
+	moHTTPClient  *http.Client
+	mvSandbox     bool
+	moLimiter     *rateLimiter
+	moRetry       *RetryPolicy
+	mtHooks       []Hook
+	mvStreamURL   string
+	moValidation  *OrderValidation
+	moInstruments *instrumentCache
+	moAliases     *AliasRegistry
+	mtAliasScopes map[string]bool
 }
 
 type Account struct {
@@ -88,6 +136,46 @@ type Candle struct {
 	Type       string    `json:"type"`
 }
 
+type OrderbookLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+}
+
+type Orderbook struct {
+	FIGI              string           `json:"figi"`
+	Time              time.Time        `json:"time"`
+	Depth             int              `json:"depth"`
+	Bids              []OrderbookLevel `json:"bids"`
+	Asks              []OrderbookLevel `json:"asks"`
+	TradeStatus       string           `json:"tradeStatus"`
+	MinPriceIncrement float64          `json:"minPriceIncrement"`
+	FaceValue         float64          `json:"faceValue"`
+	LastPrice         float64          `json:"lastPrice"`
+	ClosePrice        float64          `json:"closePrice"`
+	LimitUp           float64          `json:"limitUp"`
+	LimitDown         float64          `json:"limitDown"`
+}
+
+type MoneyAmount struct {
+	Currency string  `json:"currency"`
+	Value    float64 `json:"value"`
+}
+
+// PlacedOrder is the result of an order placement
+type PlacedOrder struct {
+	ID            string      `json:"id"`
+	FIGI          string      `json:"figi"`
+	Type          string      `json:"type"`
+	Operation     string      `json:"operation"`
+	Price         float64     `json:"price"`
+	Status        string      `json:"status"`
+	RejectReason  string      `json:"rejectReason"`
+	Message       string      `json:"message"`
+	RequestedLots int         `json:"requestedLots"`
+	ExecutedLots  int         `json:"executedLots"`
+	Commission    MoneyAmount `json:"commission"`
+}
+
 type Position struct {
 	FIGI     string  `json:"figi"`
 	Ticker   string  `json:"ticker"`
@@ -101,6 +189,17 @@ type Position struct {
 	Profit   float64 `json:"profit"`
 }
 
+type CurrencyBalance struct {
+	Currency string  `json:"currency"`
+	Balance  float64 `json:"balance"`
+	Blocked  float64 `json:"blocked"`
+}
+
+type Portfolio struct {
+	Positions  []Position        `json:"positions"`
+	Currencies []CurrencyBalance `json:"currencies"`
+}
+
 type Operation struct {
 	ID         string    `json:"id"`
 	Time       time.Time `json:"time"`
@@ -124,22 +223,65 @@ type Order struct {
 	ExecutedLots  int     `json:"executedLots"`
 }
 
+func NewClient(ivToken string, itOptions ...Option) (roClient *Client) {
+
+	roClient = &Client{}
+
+	roClient.Init(ivToken)
+
+	for _, lfOption := range itOptions {
+		lfOption(roClient)
+	}
+
+	return
+
+}
+
 func (c *Client) Init(token string) {
 
-	c.mvUrl = "https://api-invest.tinkoff.ru/openapi/"
+	c.mvUrl = DefaultURL
+	c.mvStreamURL = StreamingURL
 	c.mvToken = token
+	c.mvTimeout = DefaultTimeout
+	c.mtHeaders = http.Header{}
+	c.moHTTPClient = &http.Client{}
+	c.moLimiter = newRateLimiter(DefaultRateLimits())
+	c.moInstruments = newInstrumentCache()
+	c.moAliases = DefaultAliasRegistry()
+	c.mtAliasScopes = defaultAliasScopes()
 
 }
 
-func (c *Client) setAccount(ivId string) {
+// ForAccount returns a client bound to the broker account, sharing the transport with c
+func (c *Client) ForAccount(ivAccountID string) (roClient *Client) {
+
+	lsClient := *c
 
-	c.mvAccount = ivId
+	lsClient.mvAccount = ivAccountID
+
+	roClient = &lsClient
+
+	return
+
+}
+
+func (c *Client) AccountID() string {
+
+	return c.mvAccount
 
 }
 
 func (c *Client) GetAccounts() (rtAccounts []Account, roError error) {
 
-	lvBody, roError := c.httpRequest(http.MethodGet, "user/accounts", nil, nil)
+	rtAccounts, roError = c.GetAccountsContext(context.Background())
+
+	return
+
+}
+
+func (c *Client) GetAccountsContext(ioContext context.Context) (rtAccounts []Account, roError error) {
+
+	lvBody, roError := c.httpRequest(ioContext, http.MethodGet, "user/accounts", nil, nil)
 
 	if roError != nil {
 		return
@@ -166,11 +308,6 @@ func (c *Client) GetAccounts() (rtAccounts []Account, roError error) {
 		return
 	}
 
-	if lsResponse.Status == statusError {
-		roError = errors.New(lsResponse.Payload.Message)
-		return
-	}
-
 	for _, lsResponseAccount := range lsResponse.Payload.Accounts {
 
 		lsAccount := Account{}
@@ -188,7 +325,15 @@ func (c *Client) GetAccounts() (rtAccounts []Account, roError error) {
 
 func (c *Client) GetCurrencies() (rtCurrencies []Instrument, roError error) {
 
-	rtCurrencies, roError = c.getInstruments("currencies")
+	rtCurrencies, roError = c.GetCurrenciesContext(context.Background())
+
+	return
+
+}
+
+func (c *Client) GetCurrenciesContext(ioContext context.Context) (rtCurrencies []Instrument, roError error) {
+
+	rtCurrencies, roError = c.getInstruments(ioContext, "currencies")
 
 	return
 
@@ -196,7 +341,15 @@ func (c *Client) GetCurrencies() (rtCurrencies []Instrument, roError error) {
 
 func (c *Client) GetShares() (rtShares []Instrument, roError error) {
 
-	rtShares, roError = c.getInstruments("stocks")
+	rtShares, roError = c.GetSharesContext(context.Background())
+
+	return
+
+}
+
+func (c *Client) GetSharesContext(ioContext context.Context) (rtShares []Instrument, roError error) {
+
+	rtShares, roError = c.getInstruments(ioContext, "stocks")
 
 	return
 
@@ -204,7 +357,15 @@ func (c *Client) GetShares() (rtShares []Instrument, roError error) {
 
 func (c *Client) GetBonds() (rtBonds []Instrument, roError error) {
 
-	rtBonds, roError = c.getInstruments("bonds")
+	rtBonds, roError = c.GetBondsContext(context.Background())
+
+	return
+
+}
+
+func (c *Client) GetBondsContext(ioContext context.Context) (rtBonds []Instrument, roError error) {
+
+	rtBonds, roError = c.getInstruments(ioContext, "bonds")
 
 	return
 
@@ -212,7 +373,15 @@ func (c *Client) GetBonds() (rtBonds []Instrument, roError error) {
 
 func (c *Client) GetETFs() (rtETFs []Instrument, roError error) {
 
-	rtETFs, roError = c.getInstruments("etfs")
+	rtETFs, roError = c.GetETFsContext(context.Background())
+
+	return
+
+}
+
+func (c *Client) GetETFsContext(ioContext context.Context) (rtETFs []Instrument, roError error) {
+
+	rtETFs, roError = c.getInstruments(ioContext, "etfs")
 
 	return
 
@@ -220,25 +389,33 @@ func (c *Client) GetETFs() (rtETFs []Instrument, roError error) {
 
 func (c *Client) GetInstruments() (rtInstruments []Instrument, roError error) {
 
-	ltCurrencies, roError := c.GetCurrencies()
+	rtInstruments, roError = c.GetInstrumentsContext(context.Background())
+
+	return
+
+}
+
+func (c *Client) GetInstrumentsContext(ioContext context.Context) (rtInstruments []Instrument, roError error) {
+
+	ltCurrencies, roError := c.GetCurrenciesContext(ioContext)
 
 	if roError != nil {
 		return
 	}
 
-	ltShares, roError := c.GetShares()
+	ltShares, roError := c.GetSharesContext(ioContext)
 
 	if roError != nil {
 		return
 	}
 
-	ltBonds, roError := c.GetBonds()
+	ltBonds, roError := c.GetBondsContext(ioContext)
 
 	if roError != nil {
 		return
 	}
 
-	ltETFs, roError := c.GetETFs()
+	ltETFs, roError := c.GetETFsContext(ioContext)
 
 	if roError != nil {
 		return
@@ -253,9 +430,9 @@ func (c *Client) GetInstruments() (rtInstruments []Instrument, roError error) {
 
 }
 
-func (c *Client) getInstruments(ivType string) (rtInstruments []Instrument, roError error) {
+func (c *Client) getInstruments(ioContext context.Context, ivType string) (rtInstruments []Instrument, roError error) {
 
-	lvBody, roError := c.httpRequest(http.MethodGet, "market/"+ivType, nil, nil)
+	lvBody, roError := c.httpRequest(ioContext, http.MethodGet, "market/"+ivType, nil, nil)
 
 	if roError != nil {
 		return
@@ -290,11 +467,6 @@ func (c *Client) getInstruments(ivType string) (rtInstruments []Instrument, roEr
 		return
 	}
 
-	if lsResponse.Status == statusError {
-		roError = errors.New(lsResponse.Payload.Message)
-		return
-	}
-
 	for _, lsResponseInstrument := range lsResponse.Payload.Instruments {
 
 		lsInstrument := Instrument{}
@@ -317,11 +489,19 @@ func (c *Client) getInstruments(ivType string) (rtInstruments []Instrument, roEr
 
 func (c *Client) GetInstrumentByTicker(ivTicker string) (rsInstrument Instrument, roError error) {
 
+	rsInstrument, roError = c.GetInstrumentByTickerContext(context.Background(), ivTicker)
+
+	return
+
+}
+
+func (c *Client) GetInstrumentByTickerContext(ioContext context.Context, ivTicker string) (rsInstrument Instrument, roError error) {
+
 	loParams := url.Values{}
 
 	loParams.Add("ticker", ivTicker)
 
-	lvBody, roError := c.httpRequest(http.MethodGet, "market/search/by-ticker", loParams, nil)
+	lvBody, roError := c.httpRequest(ioContext, http.MethodGet, "market/search/by-ticker", loParams, nil)
 
 	if roError != nil {
 		return
@@ -356,11 +536,6 @@ func (c *Client) GetInstrumentByTicker(ivTicker string) (rsInstrument Instrument
 		return
 	}
 
-	if lsResponse.Status == statusError {
-		roError = errors.New(lsResponse.Payload.Message)
-		return
-	}
-
 	for _, lsResponseInstrument := range lsResponse.Payload.Instruments {
 
 		rsInstrument.Type = lsResponseInstrument.Type
@@ -381,11 +556,19 @@ func (c *Client) GetInstrumentByTicker(ivTicker string) (rsInstrument Instrument
 
 func (c *Client) GetInstrumentByFIGI(ivFIGI string) (rsInstrument Instrument, roError error) {
 
+	rsInstrument, roError = c.GetInstrumentByFIGIContext(context.Background(), ivFIGI)
+
+	return
+
+}
+
+func (c *Client) GetInstrumentByFIGIContext(ioContext context.Context, ivFIGI string) (rsInstrument Instrument, roError error) {
+
 	loParams := url.Values{}
 
 	loParams.Add("figi", ivFIGI)
 
-	lvBody, roError := c.httpRequest(http.MethodGet, "market/search/by-figi", loParams, nil)
+	lvBody, roError := c.httpRequest(ioContext, http.MethodGet, "market/search/by-figi", loParams, nil)
 
 	if roError != nil {
 		return
@@ -416,11 +599,6 @@ func (c *Client) GetInstrumentByFIGI(ivFIGI string) (rsInstrument Instrument, ro
 		return
 	}
 
-	if lsResponse.Status == statusError {
-		roError = errors.New(lsResponse.Payload.Message)
-		return
-	}
-
 	rsInstrument.Type = lsResponse.Payload.Type
 	rsInstrument.FIGI = lsResponse.Payload.Figi
 	rsInstrument.Ticker = lsResponse.Payload.Ticker
@@ -435,6 +613,18 @@ func (c *Client) GetInstrumentByFIGI(ivFIGI string) (rsInstrument Instrument, ro
 
 func (c *Client) GetCandles(ivFIGI string, ivInterval string, ivFrom time.Time, ivTo time.Time) (rtCandles []Candle, roError error) {
 
+	rtCandles, roError = c.GetCandlesContext(context.Background(), ivFIGI, ivInterval, ivFrom, ivTo)
+
+	return
+
+}
+
+func (c *Client) GetCandlesContext(ioContext context.Context, ivFIGI string, ivInterval string, ivFrom time.Time, ivTo time.Time) (rtCandles []Candle, roError error) {
+
+	if loAliases := c.aliases(AliasScopeCandles); loAliases != nil {
+		ivFIGI = loAliases.Source(ivFIGI)
+	}
+
 	loParams := url.Values{}
 
 	loParams.Add("figi", ivFIGI)
@@ -442,7 +632,7 @@ func (c *Client) GetCandles(ivFIGI string, ivInterval string, ivFrom time.Time,
 	loParams.Add("from", ivFrom.Format(time.RFC3339))
 	loParams.Add("to", ivTo.Format(time.RFC3339))
 
-	lvBody, roError := c.httpRequest(http.MethodGet, "market/candles", loParams, nil)
+	lvBody, roError := c.httpRequest(ioContext, http.MethodGet, "market/candles", loParams, nil)
 
 	if roError != nil {
 		return
@@ -477,36 +667,165 @@ func (c *Client) GetCandles(ivFIGI string, ivInterval string, ivFrom time.Time,
 		return
 	}
 
-	if lsResponse.Status == statusError {
-		roError = errors.New(lsResponse.Payload.Message)
+	for _, lsResponseCandle := range lsResponse.Payload.Candles {
+
+		lsCandle := newCandle(lsResponseCandle.Time, lsResponseCandle.O, lsResponseCandle.C, lsResponseCandle.H, lsResponseCandle.L, lsResponseCandle.V)
+
+		rtCandles = append(rtCandles, lsCandle)
+
+	}
+
+	return
+
+}
+
+func (c *Client) GetOrderbook(ivFIGI string, ivDepth int) (rsOrderbook Orderbook, roError error) {
+
+	rsOrderbook, roError = c.GetOrderbookContext(context.Background(), ivFIGI, ivDepth)
+
+	return
+
+}
+
+func (c *Client) GetOrderbookContext(ioContext context.Context, ivFIGI string, ivDepth int) (rsOrderbook Orderbook, roError error) {
+
+	loParams := url.Values{}
+
+	loParams.Add("figi", ivFIGI)
+	loParams.Add("depth", strconv.Itoa(ivDepth))
+
+	lvBody, roError := c.httpRequest(ioContext, http.MethodGet, "market/orderbook", loParams, nil)
+
+	if roError != nil {
 		return
 	}
 
-	for _, lsResponseCandle := range lsResponse.Payload.Candles {
+	type ltsResponse struct {
+		TrackingID string `json:"trackingId"`
+		Status     string `json:"status"`
+		Payload    struct {
+			Code              string           `json:"code"`
+			Message           string           `json:"message"`
+			Figi              string           `json:"figi"`
+			Depth             int              `json:"depth"`
+			Bids              []OrderbookLevel `json:"bids"`
+			Asks              []OrderbookLevel `json:"asks"`
+			TradeStatus       string           `json:"tradeStatus"`
+			MinPriceIncrement float64          `json:"minPriceIncrement"`
+			FaceValue         float64          `json:"faceValue"`
+			LastPrice         float64          `json:"lastPrice"`
+			ClosePrice        float64          `json:"closePrice"`
+			LimitUp           float64          `json:"limitUp"`
+			LimitDown         float64          `json:"limitDown"`
+		} `json:"payload"`
+	}
 
-		lsCandle := Candle{}
-
-		lsCandle.Time = lsResponseCandle.Time
-		lsCandle.High = lsResponseCandle.H
-		lsCandle.Open = lsResponseCandle.O
-		lsCandle.Close = lsResponseCandle.C
-		lsCandle.Low = lsResponseCandle.L
-		lsCandle.Volume = lsResponseCandle.V
-
-		if lsCandle.Open < lsCandle.Close {
-			lsCandle.Type = CandleTypeGreen
-			lsCandle.ShadowHigh = lsCandle.High - lsCandle.Close
-			lsCandle.Body = lsCandle.Close - lsCandle.Open
-			lsCandle.ShadowLow = lsCandle.Open - lsCandle.Low
-		} else {
-			lsCandle.Type = CandleTypeRed
-			lsCandle.ShadowHigh = lsCandle.High - lsCandle.Open
-			lsCandle.Body = lsCandle.Open - lsCandle.Close
-			lsCandle.ShadowLow = lsCandle.Close - lsCandle.Low
-		}
+	lsResponse := ltsResponse{}
 
-		rtCandles = append(rtCandles, lsCandle)
+	roError = json.Unmarshal(lvBody, &lsResponse)
+
+	if roError != nil {
+		return
+	}
+
+	rsOrderbook.FIGI = lsResponse.Payload.Figi
+	rsOrderbook.Time = time.Now()
+	rsOrderbook.Depth = lsResponse.Payload.Depth
+	rsOrderbook.Bids = lsResponse.Payload.Bids
+	rsOrderbook.Asks = lsResponse.Payload.Asks
+	rsOrderbook.TradeStatus = lsResponse.Payload.TradeStatus
+	rsOrderbook.MinPriceIncrement = lsResponse.Payload.MinPriceIncrement
+	rsOrderbook.FaceValue = lsResponse.Payload.FaceValue
+	rsOrderbook.LastPrice = lsResponse.Payload.LastPrice
+	rsOrderbook.ClosePrice = lsResponse.Payload.ClosePrice
+	rsOrderbook.LimitUp = lsResponse.Payload.LimitUp
+	rsOrderbook.LimitDown = lsResponse.Payload.LimitDown
+
+	return
+
+}
+
+func (o *Orderbook) IsTrading() bool {
+
+	return o.TradeStatus == TradeStatusNormalTrading
+
+}
+
+func (o *Orderbook) BestBid() (rsLevel OrderbookLevel, rvFound bool) {
+
+	if len(o.Bids) == 0 {
+		return
+	}
+
+	rsLevel = o.Bids[0]
+	rvFound = true
+
+	return
+
+}
+
+func (o *Orderbook) BestAsk() (rsLevel OrderbookLevel, rvFound bool) {
+
+	if len(o.Asks) == 0 {
+		return
+	}
+
+	rsLevel = o.Asks[0]
+	rvFound = true
+
+	return
+
+}
+
+// Spread returns zero when one of the sides is empty
+func (o *Orderbook) Spread() (rvSpread float64) {
+
+	lsBid, lvBidFound := o.BestBid()
+	lsAsk, lvAskFound := o.BestAsk()
+
+	if lvBidFound && lvAskFound {
+		rvSpread = lsAsk.Price - lsBid.Price
+	}
+
+	return
+
+}
+
+// MidPrice falls back to the last price when one of the sides is empty
+func (o *Orderbook) MidPrice() (rvPrice float64) {
+
+	lsBid, lvBidFound := o.BestBid()
+	lsAsk, lvAskFound := o.BestAsk()
+
+	if lvBidFound && lvAskFound {
+		rvPrice = (lsBid.Price + lsAsk.Price) / 2
+	} else {
+		rvPrice = o.LastPrice
+	}
+
+	return
 
+}
+
+func newCandle(ivTime time.Time, ivOpen float64, ivClose float64, ivHigh float64, ivLow float64, ivVolume float64) (rsCandle Candle) {
+
+	rsCandle.Time = ivTime
+	rsCandle.High = ivHigh
+	rsCandle.Open = ivOpen
+	rsCandle.Close = ivClose
+	rsCandle.Low = ivLow
+	rsCandle.Volume = ivVolume
+
+	if rsCandle.Open < rsCandle.Close {
+		rsCandle.Type = CandleTypeGreen
+		rsCandle.ShadowHigh = rsCandle.High - rsCandle.Close
+		rsCandle.Body = rsCandle.Close - rsCandle.Open
+		rsCandle.ShadowLow = rsCandle.Open - rsCandle.Low
+	} else {
+		rsCandle.Type = CandleTypeRed
+		rsCandle.ShadowHigh = rsCandle.High - rsCandle.Open
+		rsCandle.Body = rsCandle.Open - rsCandle.Close
+		rsCandle.ShadowLow = rsCandle.Close - rsCandle.Low
 	}
 
 	return
@@ -515,7 +834,15 @@ func (c *Client) GetCandles(ivFIGI string, ivInterval string, ivFrom time.Time,
 
 func (c *Client) GetPositions() (rtPositions []Position, roError error) {
 
-	lvBody, roError := c.httpRequest(http.MethodGet, "portfolio", nil, nil)
+	rtPositions, roError = c.GetPositionsContext(context.Background())
+
+	return
+
+}
+
+func (c *Client) GetPositionsContext(ioContext context.Context) (rtPositions []Position, roError error) {
+
+	lvBody, roError := c.httpRequest(ioContext, http.MethodGet, "portfolio", nil, nil)
 
 	if roError != nil {
 		return
@@ -560,10 +887,7 @@ func (c *Client) GetPositions() (rtPositions []Position, roError error) {
 		return
 	}
 
-	if lsResponse.Status == statusError {
-		roError = errors.New(lsResponse.Payload.Message)
-		return
-	}
+	loAliases := c.aliases(AliasScopePositions)
 
 	for _, lsResponsePosition := range lsResponse.Payload.Positions {
 
@@ -580,6 +904,15 @@ func (c *Client) GetPositions() (rtPositions []Position, roError error) {
 		lsPosition.Price = lsResponsePosition.AveragePositionPrice.Value
 		lsPosition.Profit = lsResponsePosition.ExpectedYield.Value
 
+		if loAliases != nil {
+			if lsAlias, lvFound := loAliases.Resolve(lsPosition.FIGI, lsPosition.Currency); lvFound {
+				lsPosition.FIGI = lsAlias.Alias
+				if lsAlias.Ticker != "" {
+					lsPosition.Ticker = lsAlias.Ticker
+				}
+			}
+		}
+
 		rtPositions = append(rtPositions, lsPosition)
 
 	}
@@ -588,27 +921,17 @@ func (c *Client) GetPositions() (rtPositions []Position, roError error) {
 
 }
 
-func (c *Client) GetOperations(ivFIGI string, ivFrom time.Time, ivTo time.Time) (rtOperations []Operation, roError error) {
+func (c *Client) GetCurrencyBalances() (rtBalances []CurrencyBalance, roError error) {
 
-	loParams := url.Values{}
+	rtBalances, roError = c.GetCurrencyBalancesContext(context.Background())
 
-	loParams.Add("from", ivFrom.Format(time.RFC3339))
-	loParams.Add("to", ivTo.Format(time.RFC3339))
-
-	if ivFIGI != "" {
-
-		lvFIGI := ivFIGI
-
-		// Workaround for TCSG share
-		if lvFIGI == FigiTCSG {
-			lvFIGI = FigiTCS
-		}
+	return
 
-		loParams.Add("figi", lvFIGI)
+}
 
-	}
+func (c *Client) GetCurrencyBalancesContext(ioContext context.Context) (rtBalances []CurrencyBalance, roError error) {
 
-	lvBody, roError := c.httpRequest(http.MethodGet, "operations", loParams, nil)
+	lvBody, roError := c.httpRequest(ioContext, http.MethodGet, "portfolio/currencies", nil, nil)
 
 	if roError != nil {
 		return
@@ -620,30 +943,11 @@ func (c *Client) GetOperations(ivFIGI string, ivFrom time.Time, ivTo time.Time)
 		Payload    struct {
 			Code       string `json:"code"`
 			Message    string `json:"message"`
-			Operations []struct {
-				ID     string `json:"id"`
-				Status string `json:"status"`
-				Trades []struct {
-					TradeID  string    `json:"tradeId"`
-					Date     time.Time `json:"date"`
-					Price    float64   `json:"price"`
-					Quantity float64   `json:"quantity"`
-				} `json:"trades"`
-				Commission struct {
-					Currency string  `json:"currency"`
-					Value    float64 `json:"value"`
-				} `json:"commission"`
-				Currency         string    `json:"currency"`
-				Payment          float64   `json:"payment"`
-				Price            float64   `json:"price"`
-				Quantity         float64   `json:"quantity"`
-				QuantityExecuted float64   `json:"quantityExecuted"`
-				Figi             string    `json:"figi"`
-				InstrumentType   string    `json:"instrumentType"`
-				IsMarginCall     bool      `json:"isMarginCall"`
-				Date             time.Time `json:"date"`
-				OperationType    string    `json:"operationType"`
-			} `json:"operations"`
+			Currencies []struct {
+				Currency string  `json:"currency"`
+				Balance  float64 `json:"balance"`
+				Blocked  float64 `json:"blocked"`
+			} `json:"currencies"`
 		} `json:"payload"`
 	}
 
@@ -655,141 +959,212 @@ func (c *Client) GetOperations(ivFIGI string, ivFrom time.Time, ivTo time.Time)
 		return
 	}
 
-	if lsResponse.Status == statusError {
-		roError = errors.New(lsResponse.Payload.Message)
+	for _, lsResponseCurrency := range lsResponse.Payload.Currencies {
+
+		lsBalance := CurrencyBalance{}
+
+		lsBalance.Currency = lsResponseCurrency.Currency
+		lsBalance.Balance = lsResponseCurrency.Balance
+		lsBalance.Blocked = lsResponseCurrency.Blocked
+
+		rtBalances = append(rtBalances, lsBalance)
+
+	}
+
+	return
+
+}
+
+func (c *Client) GetPortfolio() (rsPortfolio Portfolio, roError error) {
+
+	rsPortfolio, roError = c.GetPortfolioContext(context.Background())
+
+	return
+
+}
+
+func (c *Client) GetPortfolioContext(ioContext context.Context) (rsPortfolio Portfolio, roError error) {
+
+	rsPortfolio.Positions, roError = c.GetPositionsContext(ioContext)
+
+	if roError != nil {
 		return
 	}
 
-	for _, lsResponseOperation := range lsResponse.Payload.Operations {
+	rsPortfolio.Currencies, roError = c.GetCurrencyBalancesContext(ioContext)
 
-		// Workaround for TCS share
-		if lsResponseOperation.Figi == FigiTCS &&
-			lsResponseOperation.Currency == CurrencyRUB {
-			lsResponseOperation.Figi = FigiTCSG
-		}
+	if roError != nil {
+		return
+	}
 
-		if ivFIGI != "" {
+	return
 
-			// Workaround for TCS share
-			if ivFIGI == FigiTCS &&
-				lsResponseOperation.Figi != FigiTCS {
-				continue
-			}
+}
 
-			// Workaround for TCSG share
-			if ivFIGI == FigiTCSG &&
-				lsResponseOperation.Figi != FigiTCSG {
-				continue
-			}
+// Free returns the cash available for new orders
+func (b *CurrencyBalance) Free() float64 {
 
-		}
+	return b.Balance - b.Blocked
 
-		if lsResponseOperation.Status != statusDone {
-			continue
-		}
+}
 
-		if lsResponseOperation.OperationType == OperationBrokerCommission {
-			continue
-		}
+// FreeCash returns the buying power in the currency
+func (p *Portfolio) FreeCash(ivCurrency string) (rvAmount float64) {
 
-		if lsResponseOperation.OperationType == OperationBuyCard {
-			lsResponseOperation.OperationType = OperationBuy
+	for lvIndex := range p.Currencies {
+		if p.Currencies[lvIndex].Currency == ivCurrency {
+			rvAmount += p.Currencies[lvIndex].Free()
 		}
+	}
 
-		lsOperation := Operation{}
+	return
 
-		lsOperation.ID = lsResponseOperation.ID
-		lsOperation.Type = lsResponseOperation.OperationType
-		lsOperation.FIGI = lsResponseOperation.Figi
-		lsOperation.Currency = lsResponseOperation.Currency
-		lsOperation.Time = lsResponseOperation.Date
-		lsOperation.Quantity = lsResponseOperation.QuantityExecuted
-		lsOperation.Price = math.Abs(lsResponseOperation.Price)
-		lsOperation.Value = math.Abs(lsResponseOperation.Payment)
-		lsOperation.Commission = math.Abs(lsResponseOperation.Commission.Value)
+}
 
-		rtOperations = append(rtOperations, lsOperation)
+// Position returns the security position by FIGI
+func (p *Portfolio) Position(ivFIGI string) (rsPosition Position, rvFound bool) {
 
+	for _, lsPosition := range p.Positions {
+		if lsPosition.FIGI == ivFIGI {
+			rsPosition = lsPosition
+			rvFound = true
+			return
+		}
 	}
 
-	sort.Slice(rtOperations, func(i, j int) bool {
-		return rtOperations[i].Time.Before(rtOperations[j].Time)
-	})
+	return
+
+}
+
+func (c *Client) GetOperations(ivFIGI string, ivFrom time.Time, ivTo time.Time) (rtOperations []Operation, roError error) {
+
+	rtOperations, roError = c.GetOperationsContext(context.Background(), ivFIGI, ivFrom, ivTo)
 
 	return
 
 }
 
-func (c *Client) GetOrders() (rtOrders []Order, roError error) {
+func (c *Client) GetOperationsContext(ioContext context.Context, ivFIGI string, ivFrom time.Time, ivTo time.Time) (rtOperations []Operation, roError error) {
 
-	lvBody, roError := c.httpRequest(http.MethodGet, "orders", nil, nil)
+	ltDetails, roError := c.GetOperationDetailsContext(ioContext, ivFIGI, ivFrom, ivTo)
 
 	if roError != nil {
 		return
 	}
 
-	type ltsResponseGeneric struct {
-		TrackingID string `json:"trackingId"`
-		Status     string `json:"status"`
+	rtOperations = SimplifyOperations(ltDetails)
+
+	return
+
+}
+
+type operationResponse struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Trades []struct {
+		TradeID  string    `json:"tradeId"`
+		Date     time.Time `json:"date"`
+		Price    float64   `json:"price"`
+		Quantity float64   `json:"quantity"`
+	} `json:"trades"`
+	Commission struct {
+		Currency string  `json:"currency"`
+		Value    float64 `json:"value"`
+	} `json:"commission"`
+	Currency         string    `json:"currency"`
+	Payment          float64   `json:"payment"`
+	Price            float64   `json:"price"`
+	Quantity         float64   `json:"quantity"`
+	QuantityExecuted float64   `json:"quantityExecuted"`
+	Figi             string    `json:"figi"`
+	InstrumentType   string    `json:"instrumentType"`
+	IsMarginCall     bool      `json:"isMarginCall"`
+	Date             time.Time `json:"date"`
+	OperationType    string    `json:"operationType"`
+}
+
+// getOperations returns the operations as they are reported by the API
+func (c *Client) getOperations(ioContext context.Context, ivFIGI string, ivFrom time.Time, ivTo time.Time) (rtOperations []operationResponse, roError error) {
+
+	loParams := url.Values{}
+
+	loParams.Add("from", ivFrom.Format(time.RFC3339))
+	loParams.Add("to", ivTo.Format(time.RFC3339))
+
+	if ivFIGI != "" {
+		loParams.Add("figi", ivFIGI)
 	}
 
-	type ltsResponseError struct {
-		TrackingID string `json:"trackingId"`
-		Status     string `json:"status"`
-		Payload    struct {
-			Code    string `json:"code"`
-			Message string `json:"message"`
-		} `json:"payload"`
+	lvBody, roError := c.httpRequest(ioContext, http.MethodGet, "operations", loParams, nil)
+
+	if roError != nil {
+		return
 	}
 
-	type ltsResponseResult struct {
+	type ltsResponse struct {
 		TrackingID string `json:"trackingId"`
 		Status     string `json:"status"`
-		Payload    []struct {
-			OrderID       string  `json:"orderId"`
-			Figi          string  `json:"figi"`
-			Operation     string  `json:"operation"`
-			Status        string  `json:"status"`
-			RequestedLots int     `json:"requestedLots"`
-			ExecutedLots  int     `json:"executedLots"`
-			Type          string  `json:"type"`
-			Price         float64 `json:"price"`
+		Payload    struct {
+			Code       string              `json:"code"`
+			Message    string              `json:"message"`
+			Operations []operationResponse `json:"operations"`
 		} `json:"payload"`
 	}
 
-	lsResponseGeneric := ltsResponseGeneric{}
+	lsResponse := ltsResponse{}
 
-	roError = json.Unmarshal(lvBody, &lsResponseGeneric)
+	roError = json.Unmarshal(lvBody, &lsResponse)
 
 	if roError != nil {
 		return
 	}
 
-	if lsResponseGeneric.Status == statusError {
+	rtOperations = lsResponse.Payload.Operations
 
-		lsResponseError := ltsResponseError{}
+	return
 
-		roError = json.Unmarshal(lvBody, &lsResponseError)
+}
 
-		if roError != nil {
-			return
-		}
+func (c *Client) GetOrders() (rtOrders []Order, roError error) {
 
-		roError = errors.New(lsResponseError.Payload.Message)
+	rtOrders, roError = c.GetOrdersContext(context.Background())
 
+	return
+
+}
+
+func (c *Client) GetOrdersContext(ioContext context.Context) (rtOrders []Order, roError error) {
+
+	lvBody, roError := c.httpRequest(ioContext, http.MethodGet, "orders", nil, nil)
+
+	if roError != nil {
 		return
+	}
 
+	type ltsResponse struct {
+		TrackingID string `json:"trackingId"`
+		Status     string `json:"status"`
+		Payload    []struct {
+			OrderID       string  `json:"orderId"`
+			Figi          string  `json:"figi"`
+			Operation     string  `json:"operation"`
+			Status        string  `json:"status"`
+			RequestedLots int     `json:"requestedLots"`
+			ExecutedLots  int     `json:"executedLots"`
+			Type          string  `json:"type"`
+			Price         float64 `json:"price"`
+		} `json:"payload"`
 	}
 
-	lsResponseResult := ltsResponseResult{}
+	lsResponse := ltsResponse{}
 
-	roError = json.Unmarshal(lvBody, &lsResponseResult)
+	roError = json.Unmarshal(lvBody, &lsResponse)
 
 	if roError != nil {
 		return
 	}
 
-	for _, lsResponseOrder := range lsResponseResult.Payload {
+	for _, lsResponseOrder := range lsResponse.Payload {
 
 		lsOrder := Order{}
 
@@ -810,23 +1185,49 @@ func (c *Client) GetOrders() (rtOrders []Order, roError error) {
 
 }
 
-func (c *Client) CreateLimitOrder(ivFIGI string, ivOperation string, ivLots int, ivPrice float64) (rvOrderID string, roError error) {
+func (c *Client) CreateLimitOrder(ivFIGI string, ivOperation string, ivLots int, ivPrice float64) (rsOrder PlacedOrder, roError error) {
+
+	rsOrder, roError = c.CreateLimitOrderContext(context.Background(), ivFIGI, ivOperation, ivLots, ivPrice)
+
+	return
+
+}
+
+func (c *Client) CreateLimitOrderContext(ioContext context.Context, ivFIGI string, ivOperation string, ivLots int, ivPrice float64) (rsOrder PlacedOrder, roError error) {
 
-	rvOrderID, roError = c.createOrder(orderTypeLimit, ivFIGI, ivOperation, ivLots, ivPrice)
+	rsOrder, roError = c.createOrder(ioContext, orderTypeLimit, ivFIGI, ivOperation, ivLots, ivPrice)
 
 	return
 
 }
 
-func (c *Client) CreateMarketOrder(ivFIGI string, ivOperation string, ivLots int) (rvOrderID string, roError error) {
+func (c *Client) CreateMarketOrder(ivFIGI string, ivOperation string, ivLots int) (rsOrder PlacedOrder, roError error) {
 
-	rvOrderID, roError = c.createOrder(orderTypeMarket, ivFIGI, ivOperation, ivLots, 0)
+	rsOrder, roError = c.CreateMarketOrderContext(context.Background(), ivFIGI, ivOperation, ivLots)
 
 	return
 
 }
 
-func (c *Client) createOrder(ivType string, ivFIGI string, ivOperation string, ivLots int, ivPrice float64) (rvOrderID string, roError error) {
+func (c *Client) CreateMarketOrderContext(ioContext context.Context, ivFIGI string, ivOperation string, ivLots int) (rsOrder PlacedOrder, roError error) {
+
+	rsOrder, roError = c.createOrder(ioContext, orderTypeMarket, ivFIGI, ivOperation, ivLots, 0)
+
+	return
+
+}
+
+func (c *Client) createOrder(ioContext context.Context, ivType string, ivFIGI string, ivOperation string, ivLots int, ivPrice float64) (rsOrder PlacedOrder, roError error) {
+
+	if c.moValidation != nil {
+
+		ivPrice, roError = c.validateOrder(ioContext, ivType, ivFIGI, ivOperation, ivLots, ivPrice)
+
+		if roError != nil {
+			return
+		}
+
+	}
 
 	loParams := url.Values{}
 
@@ -850,7 +1251,7 @@ func (c *Client) createOrder(ivType string, ivFIGI string, ivOperation string, i
 		return
 	}
 
-	lvBody, roError = c.httpRequest(http.MethodPost, "orders/"+ivType+"-order", loParams, lvBody)
+	lvBody, roError = c.httpRequest(ioContext, http.MethodPost, "orders/"+ivType+"-order", loParams, lvBody)
 
 	if roError != nil {
 		return
@@ -860,17 +1261,15 @@ func (c *Client) createOrder(ivType string, ivFIGI string, ivOperation string, i
 		TrackingID string `json:"trackingId"`
 		Status     string `json:"status"`
 		Payload    struct {
-			Code          string `json:"code"`
-			Message       string `json:"message"`
-			OrderID       string `json:"orderId"`
-			Operation     string `json:"operation"`
-			RejectReason  string `json:"rejectReason"`
-			RequestedLots int    `json:"requestedLots"`
-			ExecutedLots  int    `json:"executedLots"`
-			Commission    struct {
-				Currency string `json:"currency"`
-				Value    int    `json:"value"`
-			} `json:"commission"`
+			Code          string      `json:"code"`
+			Message       string      `json:"message"`
+			OrderID       string      `json:"orderId"`
+			Operation     string      `json:"operation"`
+			Status        string      `json:"status"`
+			RejectReason  string      `json:"rejectReason"`
+			RequestedLots int         `json:"requestedLots"`
+			ExecutedLots  int         `json:"executedLots"`
+			Commission    MoneyAmount `json:"commission"`
 		} `json:"payload"`
 	}
 
@@ -882,12 +1281,26 @@ func (c *Client) createOrder(ivType string, ivFIGI string, ivOperation string, i
 		return
 	}
 
-	if lsResponse.Status == statusError {
-		roError = errors.New(lsResponse.Payload.Message)
-		return
+	rsOrder.ID = lsResponse.Payload.OrderID
+	rsOrder.FIGI = ivFIGI
+	rsOrder.Type = ivType
+	rsOrder.Operation = lsResponse.Payload.Operation
+	rsOrder.Price = ivPrice
+	rsOrder.Status = lsResponse.Payload.Status
+	rsOrder.RejectReason = lsResponse.Payload.RejectReason
+	rsOrder.Message = lsResponse.Payload.Message
+	rsOrder.RequestedLots = lsResponse.Payload.RequestedLots
+	rsOrder.ExecutedLots = lsResponse.Payload.ExecutedLots
+	rsOrder.Commission = lsResponse.Payload.Commission
+
+	if rsOrder.Operation == "" {
+		rsOrder.Operation = ivOperation
 	}
 
-	rvOrderID = lsResponse.Payload.OrderID
+	if rsOrder.Status == OrderStatusRejected {
+		roError = &OrderRejectedError{OrderID: rsOrder.ID, Reason: rsOrder.RejectReason, Message: rsOrder.Message}
+		return
+	}
 
 	return
 
@@ -895,11 +1308,19 @@ func (c *Client) createOrder(ivType string, ivFIGI string, ivOperation string, i
 
 func (c *Client) CancelOrder(ivOrderID string) (roError error) {
 
+	roError = c.CancelOrderContext(context.Background(), ivOrderID)
+
+	return
+
+}
+
+func (c *Client) CancelOrderContext(ioContext context.Context, ivOrderID string) (roError error) {
+
 	loParams := url.Values{}
 
 	loParams.Add("orderId", ivOrderID)
 
-	lvBody, roError := c.httpRequest(http.MethodPost, "orders/cancel", loParams, nil)
+	lvBody, roError := c.httpRequest(ioContext, http.MethodPost, "orders/cancel", loParams, nil)
 
 	if roError != nil {
 		return
@@ -922,84 +1343,151 @@ func (c *Client) CancelOrder(ivOrderID string) (roError error) {
 		return
 	}
 
-	if lsResponse.Status == statusError {
-		roError = errors.New(lsResponse.Payload.Message)
-		return
-	}
-
 	return
 
 }
 
-func (c *Client) httpRequest(ivMethod string, ivPath string, ioParams url.Values, ivBody []byte) (rvBody []byte, roError error) {
+func (c *Client) httpRequest(ioContext context.Context, ivMethod string, ivPath string, ioParams url.Values, ivBody []byte) (rvBody []byte, roError error) {
 
 	lvUrl := c.mvUrl + ivPath
 
-	if c.mvAccount != "" {
-		ioParams.Add("brokerAccountId", c.mvAccount)
+	loParams := url.Values{}
+
+	for lvKey, ltValues := range ioParams {
+		loParams[lvKey] = append([]string(nil), ltValues...)
 	}
 
-	if ioParams != nil {
-		lvUrl = lvUrl + "?" + ioParams.Encode()
+	if c.mvAccount != "" {
+		loParams.Set("brokerAccountId", c.mvAccount)
 	}
 
-	loRequest, roError := http.NewRequest(ivMethod, lvUrl, bytes.NewBuffer(ivBody))
+	if len(loParams) > 0 {
+		lvUrl = lvUrl + "?" + loParams.Encode()
+	}
 
-	if roError != nil {
-		return
+	if _, lvHasDeadline := ioContext.Deadline(); !lvHasDeadline && c.mvTimeout > 0 {
+		var lfCancel context.CancelFunc
+		ioContext, lfCancel = context.WithTimeout(ioContext, c.mvTimeout)
+		defer lfCancel()
 	}
 
-	loRequest.Header.Add("Authorization", "Bearer "+c.mvToken)
+	for lvAttempt := 1; ; lvAttempt++ {
 
-	loClient := http.Client{}
+		var loHeader http.Header
 
-	loResponse, roError := loClient.Do(loRequest)
+		rvBody, loHeader, roError = c.httpAttempt(ioContext, ivMethod, lvUrl, ivPath, ivBody, lvAttempt)
+
+		if roError == nil {
+			return
+		}
+
+		lvDelay, lvRetry := c.moRetry.delay(ivMethod, lvAttempt, loHeader, roError)
+
+		if !lvRetry || ioContext.Err() != nil {
+			return
+		}
+
+		if c.moRetry.OnRetry != nil {
+			c.moRetry.OnRetry(RetryEvent{
+				Method:  ivMethod,
+				Path:    ivPath,
+				Attempt: lvAttempt,
+				Delay:   lvDelay,
+				Error:   roError,
+			})
+		}
+
+		loTimer := time.NewTimer(lvDelay)
+
+		select {
+		case <-loTimer.C:
+		case <-ioContext.Done():
+			loTimer.Stop()
+			return
+		}
 
-	if roError != nil {
-		return
 	}
 
-	defer loResponse.Body.Close()
+}
 
-	rvBody, roError = io.ReadAll(loResponse.Body)
+func (c *Client) httpAttempt(ioContext context.Context, ivMethod string, ivUrl string, ivPath string, ivBody []byte, ivAttempt int) (rvBody []byte, roHeader http.Header, roError error) {
+
+	if c.moLimiter != nil {
+
+		roError = c.moLimiter.wait(ioContext, rateLimitGroup(ivPath))
+
+		if roError != nil {
+			return
+		}
+
+	}
+
+	loRequest, roError := http.NewRequestWithContext(ioContext, ivMethod, ivUrl, bytes.NewBuffer(ivBody))
 
 	if roError != nil {
 		return
 	}
 
-	if loResponse.StatusCode != http.StatusOK {
+	for lvHeader, ltValues := range c.mtHeaders {
+		for _, lvValue := range ltValues {
+			loRequest.Header.Add(lvHeader, lvValue)
+		}
+	}
 
-		lvErrorText := ""
+	if c.mvUserAgent != "" {
+		loRequest.Header.Set("User-Agent", c.mvUserAgent)
+	}
 
-		if len(rvBody) == 0 {
+	loRequest.Header.Set("Authorization", "Bearer "+c.mvToken)
 
-			lvErrorText = fmt.Sprintf("%v (%v)", http.StatusText(loResponse.StatusCode), loResponse.StatusCode)
+	lvStatusCode := 0
 
-		} else {
+	if len(c.mtHooks) > 0 {
 
-			type ltsResponse struct {
-				TrackingID string `json:"trackingId"`
-				Status     string `json:"status"`
-				Payload    struct {
-					Code    string `json:"code"`
-					Message string `json:"message"`
-				} `json:"payload"`
-			}
+		lsInfo := newRequestInfo(loRequest, ivPath, ivAttempt)
 
-			lsResponse := ltsResponse{}
+		c.runHooks(ioContext, &lsInfo, false)
 
-			roError = json.Unmarshal(rvBody, &lsResponse)
+		lsInfo.Start = time.Now()
 
-			if roError == nil {
-				lvErrorText = fmt.Sprintf("%v (%v)", lsResponse.Payload.Message, lsResponse.Payload.Code)
-			} else {
-				lvErrorText = string(rvBody)
-			}
+		defer func() {
+			lsInfo.Latency = time.Since(lsInfo.Start)
+			lsInfo.StatusCode = lvStatusCode
+			lsInfo.TrackingID = parseTrackingID(rvBody)
+			lsInfo.Error = roError
+			c.runHooks(ioContext, &lsInfo, true)
+		}()
 
-		}
+	}
+
+	loClient := c.moHTTPClient
+
+	if loClient == nil {
+		loClient = http.DefaultClient
+	}
+
+	loResponse, roError := loClient.Do(loRequest)
+
+	if roError != nil {
+		return
+	}
+
+	lvStatusCode = loResponse.StatusCode
+
+	defer loResponse.Body.Close()
+
+	roHeader = loResponse.Header
+
+	rvBody, roError = io.ReadAll(loResponse.Body)
+
+	if roError != nil {
+		return
+	}
 
-		roError = errors.New(lvErrorText)
+	loAPIError := parseAPIError(ivPath, loResponse.StatusCode, rvBody)
 
+	if loAPIError != nil {
+		roError = loAPIError
 		return
 	}
 
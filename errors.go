@@ -0,0 +1,158 @@
+package tinvestclient
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrRateLimited         = errors.New("rate limited")
+	ErrUnauthorized        = errors.New("unauthorized")
+	ErrNotFound            = errors.New("not found")
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	ErrOrderRejected       = errors.New("order rejected")
+	ErrInvalidOrder        = errors.New("invalid order")
+)
+
+// APIError is returned for every failed OpenAPI call, match it with errors.As
+// or compare the kind of failure with errors.Is and the Err* sentinels
+type APIError struct {
+	HTTPStatus int
+	Code       string
+	Message    string
+	TrackingID string
+	Endpoint   string
+}
+
+func (e *APIError) Error() string {
+
+	lvText := e.Message
+
+	if lvText == "" {
+		lvText = http.StatusText(e.HTTPStatus)
+	}
+
+	if e.Code != "" {
+		lvText = fmt.Sprintf("%v (%v)", lvText, e.Code)
+	}
+
+	lvText = fmt.Sprintf("%v: %v, http status %v", e.Endpoint, lvText, e.HTTPStatus)
+
+	if e.TrackingID != "" {
+		lvText = fmt.Sprintf("%v, tracking id %v", lvText, e.TrackingID)
+	}
+
+	return lvText
+
+}
+
+func (e *APIError) Is(ioTarget error) bool {
+
+	lvCode := strings.ToUpper(strings.ReplaceAll(e.Code, "_", ""))
+
+	switch ioTarget {
+
+	case ErrRateLimited:
+		return e.HTTPStatus == http.StatusTooManyRequests ||
+			lvCode == "TOOMANYREQUESTS" ||
+			lvCode == "RATELIMITEXCEEDED"
+
+	case ErrUnauthorized:
+		return e.HTTPStatus == http.StatusUnauthorized ||
+			e.HTTPStatus == http.StatusForbidden ||
+			lvCode == "UNAUTHORIZED" ||
+			lvCode == "FORBIDDEN"
+
+	case ErrNotFound:
+		return e.HTTPStatus == http.StatusNotFound ||
+			strings.HasSuffix(lvCode, "NOTFOUND")
+
+	case ErrInsufficientBalance:
+		return lvCode == "NOTENOUGHBALANCE" ||
+			lvCode == "INSUFFICIENTBALANCE" ||
+			lvCode == "INSUFFICIENTFUNDS"
+
+	case ErrOrderRejected:
+		return lvCode == "ORDERERROR" ||
+			lvCode == "ORDERREJECTED" ||
+			lvCode == "REJECTED"
+
+	}
+
+	return false
+
+}
+
+// OrderRejectedError is returned when the broker accepted the request but rejected the order
+type OrderRejectedError struct {
+	OrderID string
+	Reason  string
+	Message string
+}
+
+func (e *OrderRejectedError) Error() string {
+
+	lvText := fmt.Sprintf("order %v rejected: %v", e.OrderID, e.Reason)
+
+	if e.Message != "" {
+		lvText = fmt.Sprintf("%v (%v)", lvText, e.Message)
+	}
+
+	return lvText
+
+}
+
+func (e *OrderRejectedError) Is(ioTarget error) bool {
+
+	return ioTarget == ErrOrderRejected
+
+}
+
+// parseAPIError returns nil when the response is a successful one
+func parseAPIError(ivEndpoint string, ivHTTPStatus int, ivBody []byte) (roError *APIError) {
+
+	type ltsResponse struct {
+		TrackingID string          `json:"trackingId"`
+		Status     string          `json:"status"`
+		Payload    json.RawMessage `json:"payload"`
+	}
+
+	type ltsPayload struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+
+	lsResponse := ltsResponse{}
+
+	lvParsed := json.Unmarshal(ivBody, &lsResponse) == nil
+
+	if ivHTTPStatus == http.StatusOK &&
+		(!lvParsed || lsResponse.Status != statusError) {
+		return
+	}
+
+	roError = &APIError{}
+
+	roError.HTTPStatus = ivHTTPStatus
+	roError.Endpoint = ivEndpoint
+
+	if !lvParsed {
+		roError.Message = strings.TrimSpace(string(ivBody))
+		return
+	}
+
+	roError.TrackingID = lsResponse.TrackingID
+
+	lsPayload := ltsPayload{}
+
+	if json.Unmarshal(lsResponse.Payload, &lsPayload) == nil {
+		roError.Code = lsPayload.Code
+		roError.Message = lsPayload.Message
+	}
+
+	return
+
+}
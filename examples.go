@@ -87,14 +87,16 @@ func Examples(ivToken string)  {
 	// Create limit order
 	fmt.Println("Create limit order:")
 
-	lvOrderID, loError := loClient.CreateLimitOrder(FigiAAPL, OperationBuy, 1, 100 )
+	lsOrder, loError := loClient.CreateLimitOrder(FigiAAPL, OperationBuy, 1, 100 )
 
 	if loError != nil {
 		fmt.Printf("Error: %+v\n", loError)
 		return
 	}
 
-	fmt.Printf("Order ID %v was created\n", lvOrderID)
+	lvOrderID := lsOrder.ID
+
+	fmt.Printf("Order ID %v was created with status %v\n", lvOrderID, lsOrder.Status)
 
 	// Get orders
 	fmt.Println("Orders:")
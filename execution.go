@@ -0,0 +1,595 @@
+package tinvestclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ExecutionTWAP             = "TWAP"
+	ExecutionVWAP             = "VWAP"
+	ExecutionStatusRunning    = "Running"
+	ExecutionStatusPaused     = "Paused"
+	ExecutionStatusDone       = "Done"
+	ExecutionStatusCancelled  = "Cancelled"
+	ExecutionStatusFailed     = "Failed"
+	defaultExecutionSlices    = 10
+	defaultExecutionVolumeDay = 5
+)
+
+// ExecutionRequest describes a parent order split into child orders over
+// Duration. A zero LimitPrice sends market child orders, otherwise limit
+// orders at LimitPrice which are cancelled at the end of their slice; the
+// unfilled lots move to the next slice and, with FinishWithMarket, the lots
+// left after the last slice are sent as a market order.
+type ExecutionRequest struct {
+	FIGI             string
+	Operation        string
+	Lots             int
+	Duration         time.Duration
+	Slices           int
+	LimitPrice       float64
+	FinishWithMarket bool
+	VolumeDays       int
+	OnProgress       func(ExecutionReport)
+}
+
+// ExecutionReport is the progress of an execution and, once it is finished,
+// its summary. Slippage is the average price against the arrival price, the
+// mid price of the orderbook at the start; positive values are a loss.
+type ExecutionReport struct {
+	Algorithm     string    `json:"algorithm"`
+	FIGI          string    `json:"figi"`
+	Operation     string    `json:"operation"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error"`
+	RequestedLots int       `json:"requestedLots"`
+	ExecutedLots  int       `json:"executedLots"`
+	Slice         int       `json:"slice"`
+	Slices        int       `json:"slices"`
+	Orders        int       `json:"orders"`
+	AveragePrice  float64   `json:"averagePrice"`
+	ArrivalPrice  float64   `json:"arrivalPrice"`
+	Slippage      float64   `json:"slippage"`
+	SlippageBps   float64   `json:"slippageBps"`
+	Started       time.Time `json:"started"`
+	Finished      time.Time `json:"finished"`
+}
+
+// Execution is a running TWAP or VWAP order
+type Execution struct {
+	moClient  *Client
+	moTracker *OrderTracker
+	msRequest ExecutionRequest
+	mtLots    []int
+	moMutex   sync.Mutex
+	msReport  ExecutionReport
+	mvValue   float64
+	mvPaused  bool
+	mcResume  chan struct{}
+	mfCancel  context.CancelFunc
+	mcDone    chan struct{}
+}
+
+// StartTWAP sends equal slices of the order at even intervals
+func (c *Client) StartTWAP(ioContext context.Context, isRequest ExecutionRequest) (roExecution *Execution, roError error) {
+
+	roExecution, roError = c.startExecution(ioContext, ExecutionTWAP, isRequest)
+
+	return
+
+}
+
+// StartVWAP sizes the slices by the average intraday volume of the
+// instrument over the last VolumeDays days at the time of each slice
+func (c *Client) StartVWAP(ioContext context.Context, isRequest ExecutionRequest) (roExecution *Execution, roError error) {
+
+	roExecution, roError = c.startExecution(ioContext, ExecutionVWAP, isRequest)
+
+	return
+
+}
+
+func (c *Client) startExecution(ioContext context.Context, ivAlgorithm string, isRequest ExecutionRequest) (roExecution *Execution, roError error) {
+
+	if isRequest.Operation != OperationBuy && isRequest.Operation != OperationSell {
+		roError = fmt.Errorf("operation %q is not supported", isRequest.Operation)
+		return
+	}
+
+	if isRequest.Lots <= 0 {
+		roError = fmt.Errorf("lots %v must be positive", isRequest.Lots)
+		return
+	}
+
+	if isRequest.Duration <= 0 {
+		roError = fmt.Errorf("duration %v must be positive", isRequest.Duration)
+		return
+	}
+
+	if isRequest.Slices <= 0 {
+		isRequest.Slices = defaultExecutionSlices
+	}
+
+	if isRequest.Slices > isRequest.Lots {
+		isRequest.Slices = isRequest.Lots
+	}
+
+	if isRequest.VolumeDays <= 0 {
+		isRequest.VolumeDays = defaultExecutionVolumeDay
+	}
+
+	lsOrderbook, roError := c.GetOrderbookContext(ioContext, isRequest.FIGI, 1)
+
+	if roError != nil {
+		return
+	}
+
+	lvStart := time.Now()
+
+	ltWeights := make([]float64, isRequest.Slices)
+
+	for lvIndex := range ltWeights {
+		ltWeights[lvIndex] = 1
+	}
+
+	if ivAlgorithm == ExecutionVWAP {
+
+		ltWeights, roError = c.volumeProfile(ioContext, isRequest, lvStart)
+
+		if roError != nil {
+			return
+		}
+
+	}
+
+	lsExecution := Execution{}
+
+	lsExecution.moClient = c
+	lsExecution.moTracker = c.NewOrderTracker(WithPollInterval(time.Second))
+	lsExecution.msRequest = isRequest
+	lsExecution.mtLots = allocateLots(isRequest.Lots, ltWeights)
+	lsExecution.mcDone = make(chan struct{})
+	lsExecution.mcResume = make(chan struct{}, 1)
+
+	lsExecution.msReport.Algorithm = ivAlgorithm
+	lsExecution.msReport.FIGI = isRequest.FIGI
+	lsExecution.msReport.Operation = isRequest.Operation
+	lsExecution.msReport.Status = ExecutionStatusRunning
+	lsExecution.msReport.RequestedLots = isRequest.Lots
+	lsExecution.msReport.Slices = isRequest.Slices
+	lsExecution.msReport.ArrivalPrice = lsOrderbook.MidPrice()
+	lsExecution.msReport.Started = lvStart
+
+	// The execution outlives the context of the start request
+	var loContext context.Context
+
+	loContext, lsExecution.mfCancel = context.WithCancel(context.Background())
+
+	roExecution = &lsExecution
+
+	go roExecution.run(loContext)
+
+	return
+
+}
+
+// volumeProfile returns the traded volume of the past days in the time of day of every slice
+func (c *Client) volumeProfile(ioContext context.Context, isRequest ExecutionRequest, ivStart time.Time) (rtWeights []float64, roError error) {
+
+	lvStep := isRequest.Duration / time.Duration(isRequest.Slices)
+
+	lvInterval := IntervalMin5
+
+	switch {
+	case lvStep >= time.Hour || isRequest.Duration > 24*time.Hour:
+		lvInterval = IntervalHour
+	case lvStep < 5*time.Minute:
+		lvInterval = IntervalMin1
+	}
+
+	rtWeights = make([]float64, isRequest.Slices)
+
+	lvTotal := 0.0
+
+	for lvDay := 1; lvDay <= isRequest.VolumeDays; lvDay++ {
+
+		lvFrom := ivStart.AddDate(0, 0, -lvDay)
+		lvTo := lvFrom.Add(isRequest.Duration)
+
+		// Long executions exceed the range the API accepts for one request
+		ltCandles, loError := c.GetCandleHistoryContext(ioContext, isRequest.FIGI, lvInterval, lvFrom, lvTo)
+
+		if loError != nil {
+			roError = loError
+			return
+		}
+
+		for _, lsCandle := range ltCandles {
+
+			lvIndex := int(lsCandle.Time.Sub(lvFrom) / lvStep)
+
+			if lvIndex < 0 || lvIndex >= len(rtWeights) {
+				continue
+			}
+
+			rtWeights[lvIndex] += lsCandle.Volume
+			lvTotal += lsCandle.Volume
+
+		}
+
+	}
+
+	// Without history, e.g. for a new listing, the slices are equal
+	if lvTotal == 0 {
+		for lvIndex := range rtWeights {
+			rtWeights[lvIndex] = 1
+		}
+	}
+
+	return
+
+}
+
+// allocateLots splits the lots by the weights using the largest remainders
+func allocateLots(ivLots int, itWeights []float64) (rtLots []int) {
+
+	rtLots = make([]int, len(itWeights))
+
+	lvTotal := 0.0
+
+	for _, lvWeight := range itWeights {
+		lvTotal += lvWeight
+	}
+
+	if lvTotal == 0 {
+		return
+	}
+
+	type ltsRemainder struct {
+		Index     int
+		Remainder float64
+	}
+
+	ltRemainders := make([]ltsRemainder, len(itWeights))
+
+	lvAllocated := 0
+
+	for lvIndex, lvWeight := range itWeights {
+
+		lvShare := float64(ivLots) * lvWeight / lvTotal
+
+		rtLots[lvIndex] = int(math.Floor(lvShare))
+		lvAllocated += rtLots[lvIndex]
+
+		ltRemainders[lvIndex] = ltsRemainder{Index: lvIndex, Remainder: lvShare - math.Floor(lvShare)}
+
+	}
+
+	sort.SliceStable(ltRemainders, func(i, j int) bool {
+		return ltRemainders[i].Remainder > ltRemainders[j].Remainder
+	})
+
+	for lvIndex := 0; lvAllocated < ivLots; lvIndex++ {
+		rtLots[ltRemainders[lvIndex%len(ltRemainders)].Index]++
+		lvAllocated++
+	}
+
+	return
+
+}
+
+// Report returns the current progress
+func (e *Execution) Report() ExecutionReport {
+
+	e.moMutex.Lock()
+	defer e.moMutex.Unlock()
+
+	return e.msReport
+
+}
+
+// Pause skips the following slices until Resume, their lots move to the next
+// sent slice. Paused at the end of the window, the execution waits for Resume
+// and then sends the remaining lots at once, or for Cancel.
+func (e *Execution) Pause() {
+
+	e.setPaused(true)
+
+}
+
+func (e *Execution) Resume() {
+
+	e.setPaused(false)
+
+}
+
+func (e *Execution) setPaused(ivPaused bool) {
+
+	e.moMutex.Lock()
+
+	if e.msReport.Status != ExecutionStatusRunning && e.msReport.Status != ExecutionStatusPaused {
+		e.moMutex.Unlock()
+		return
+	}
+
+	e.mvPaused = ivPaused
+
+	if ivPaused {
+		e.msReport.Status = ExecutionStatusPaused
+	} else {
+		e.msReport.Status = ExecutionStatusRunning
+		select {
+		case e.mcResume <- struct{}{}:
+		default:
+		}
+	}
+
+	e.moMutex.Unlock()
+
+	e.progress()
+
+}
+
+// Cancel stops the execution and cancels the active child order
+func (e *Execution) Cancel() {
+
+	e.mfCancel()
+
+}
+
+// Wait blocks until the execution is finished and returns its summary
+func (e *Execution) Wait(ioContext context.Context) (rsReport ExecutionReport, roError error) {
+
+	select {
+	case <-e.mcDone:
+	case <-ioContext.Done():
+		roError = ioContext.Err()
+	}
+
+	rsReport = e.Report()
+
+	if roError == nil && rsReport.Error != "" {
+		roError = errors.New(rsReport.Error)
+	}
+
+	return
+
+}
+
+func (e *Execution) progress() {
+
+	if e.msRequest.OnProgress != nil {
+		e.msRequest.OnProgress(e.Report())
+	}
+
+}
+
+func (e *Execution) run(ioContext context.Context) {
+
+	defer close(e.mcDone)
+
+	lvStep := e.msRequest.Duration / time.Duration(len(e.mtLots))
+	lvTarget := 0
+
+	var loError error
+
+	for lvIndex, lvLots := range e.mtLots {
+
+		lvTarget += lvLots
+
+		loTimer := time.NewTimer(time.Until(e.msReport.Started.Add(time.Duration(lvIndex) * lvStep)))
+
+		select {
+		case <-loTimer.C:
+		case <-ioContext.Done():
+			loTimer.Stop()
+		}
+
+		if ioContext.Err() != nil {
+			break
+		}
+
+		e.moMutex.Lock()
+		e.msReport.Slice = lvIndex + 1
+		lvPaused := e.mvPaused
+		lvLots = lvTarget - e.msReport.ExecutedLots
+		e.moMutex.Unlock()
+
+		if lvPaused || lvLots <= 0 {
+			e.progress()
+			continue
+		}
+
+		lvDeadline := e.msReport.Started.Add(time.Duration(lvIndex+1) * lvStep)
+
+		loError = e.child(ioContext, lvLots, e.msRequest.LimitPrice, lvDeadline)
+
+		e.progress()
+
+		if loError != nil {
+			break
+		}
+
+	}
+
+	// Paused at the end of the window the execution waits for Resume or Cancel
+	if loError == nil && e.waitResume(ioContext) {
+		loError = e.child(ioContext, e.remainingLots(), e.msRequest.LimitPrice, time.Now().Add(lvStep))
+		e.progress()
+	}
+
+	lvLots := e.remainingLots()
+
+	if loError == nil && ioContext.Err() == nil && e.msRequest.FinishWithMarket && e.msRequest.LimitPrice > 0 && lvLots > 0 {
+		loError = e.child(ioContext, lvLots, 0, time.Time{})
+	}
+
+	e.moMutex.Lock()
+
+	switch {
+	case loError != nil && ioContext.Err() == nil:
+		e.msReport.Status = ExecutionStatusFailed
+		e.msReport.Error = loError.Error()
+	case ioContext.Err() != nil:
+		e.msReport.Status = ExecutionStatusCancelled
+	default:
+		e.msReport.Status = ExecutionStatusDone
+	}
+
+	e.msReport.Finished = time.Now()
+
+	e.moMutex.Unlock()
+
+	e.mfCancel()
+
+	e.progress()
+
+}
+
+func (e *Execution) remainingLots() int {
+
+	e.moMutex.Lock()
+	defer e.moMutex.Unlock()
+
+	return e.msRequest.Lots - e.msReport.ExecutedLots
+
+}
+
+// waitResume blocks while the execution is paused with lots left and reports whether it was resumed
+func (e *Execution) waitResume(ioContext context.Context) (rvResumed bool) {
+
+	for e.remainingLots() > 0 {
+
+		e.moMutex.Lock()
+		lvPaused := e.mvPaused
+		e.moMutex.Unlock()
+
+		if !lvPaused {
+			return
+		}
+
+		select {
+		case <-e.mcResume:
+			rvResumed = true
+		case <-ioContext.Done():
+			rvResumed = false
+			return
+		}
+
+	}
+
+	return
+
+}
+
+// child sends one order and waits for it until the deadline, a zero price sends a market order
+func (e *Execution) child(ioContext context.Context, ivLots int, ivPrice float64, ivDeadline time.Time) (roError error) {
+
+	var lsOrder PlacedOrder
+
+	if ivPrice > 0 {
+		lsOrder, roError = e.moClient.CreateLimitOrderContext(ioContext, e.msRequest.FIGI, e.msRequest.Operation, ivLots, ivPrice)
+	} else {
+		lsOrder, roError = e.moClient.CreateMarketOrderContext(ioContext, e.msRequest.FIGI, e.msRequest.Operation, ivLots)
+	}
+
+	if roError != nil {
+		return
+	}
+
+	e.moMutex.Lock()
+	e.msReport.Orders++
+	e.moMutex.Unlock()
+
+	loContext := ioContext
+	lfCancel := context.CancelFunc(func() {})
+
+	// Market orders are waited for without a deadline
+	if ivPrice > 0 {
+		loContext, lfCancel = context.WithDeadline(ioContext, ivDeadline)
+	}
+
+	// An order filled at once is not waited for in the operations
+	lsState := newPlacedOrderTrackState(lsOrder)
+
+	lsEvent, loError := e.moTracker.waitForOrder(loContext, lsState)
+
+	lfCancel()
+
+	if loError != nil {
+
+		// The order outlived its slice or the execution was cancelled
+		loContext, lfCancel = context.WithTimeout(context.Background(), time.Minute)
+
+		defer lfCancel()
+
+		// A failed cancel means the order is final already
+		e.moClient.CancelOrderContext(loContext, lsOrder.ID)
+
+		lsEvent, loError = e.moTracker.waitForOrder(loContext, lsState)
+
+		if loError != nil && roError == nil && ioContext.Err() == nil {
+			roError = loError
+		}
+
+		// The executed lots of an order without an operation are a lower bound,
+		// sending the rest could exceed the parent order
+		if lsEvent.Type == OrderEventUnknown && ioContext.Err() == nil {
+			roError = fmt.Errorf("order %v: %w", lsOrder.ID, ErrOrderStateUnknown)
+		}
+
+	}
+
+	if lsEvent.Type == OrderEventRejected && ioContext.Err() == nil {
+		roError = fmt.Errorf("order %v: %w", lsOrder.ID, ErrOrderRejected)
+	}
+
+	e.fill(lsEvent, ivPrice)
+
+	return
+
+}
+
+func (e *Execution) fill(isEvent OrderEvent, ivPrice float64) {
+
+	if isEvent.ExecutedLots <= 0 {
+		return
+	}
+
+	lvPrice := isEvent.AveragePrice
+
+	if lvPrice == 0 {
+		lvPrice = ivPrice
+	}
+
+	e.moMutex.Lock()
+	defer e.moMutex.Unlock()
+
+	if lvPrice == 0 {
+		lvPrice = e.msReport.ArrivalPrice
+	}
+
+	e.mvValue += lvPrice * float64(isEvent.ExecutedLots)
+
+	e.msReport.ExecutedLots += isEvent.ExecutedLots
+	e.msReport.AveragePrice = e.mvValue / float64(e.msReport.ExecutedLots)
+
+	if e.msReport.ArrivalPrice > 0 {
+
+		e.msReport.Slippage = e.msReport.AveragePrice - e.msReport.ArrivalPrice
+
+		if e.msRequest.Operation == OperationSell {
+			e.msReport.Slippage = -e.msReport.Slippage
+		}
+
+		e.msReport.SlippageBps = e.msReport.Slippage / e.msReport.ArrivalPrice * 10000
+
+	}
+
+}
@@ -0,0 +1,42 @@
+package tinvestclient
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestExecutionFilledAtPlacement(t *testing.T) {
+
+	loBroker, loClient := newTestBroker(t)
+
+	// Market orders are filled at once and booked later than the execution looks
+	loBroker.mfPlace = func(ioBroker *testBroker, isPlacement testPlacement) map[string]interface{} {
+		return placedPayload(isPlacement, OrderStatusFill, isPlacement.Lots)
+	}
+
+	loExecution, loError := loClient.StartTWAP(context.Background(), ExecutionRequest{FIGI: testFIGI, Operation: OperationBuy, Lots: 2, Slices: 2, Duration: 100 * time.Millisecond})
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	loContext, lfCancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer lfCancel()
+
+	lsReport, loError := loExecution.Wait(loContext)
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	if lsReport.Status != ExecutionStatusDone || lsReport.ExecutedLots != 2 || lsReport.Orders != 2 {
+		t.Errorf("report %+v, want done with 2 lots in 2 orders", lsReport)
+	}
+
+	if lvLots := loBroker.placedLots(); lvLots != 2 {
+		t.Errorf("%v lots sent, want 2", lvLots)
+	}
+
+}
@@ -1,3 +1,5 @@
 module github.com/ivangurin/tinvest-client-go
 
 go 1.16
+
+require github.com/gorilla/websocket v1.4.2
@@ -0,0 +1,928 @@
+package tinvestclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	OrderGroupOCO        = "OCO"
+	OrderGroupBracket    = "Bracket"
+	LegKindLimit         = "Limit"
+	LegKindStop          = "Stop"
+	LegRoleEntry         = "Entry"
+	LegRoleStop          = "Stop"
+	LegRoleTarget        = "Target"
+	LegStatusNew         = "New"
+	LegStatusActive      = "Active"
+	LegStatusFilled      = "Filled"
+	LegStatusCancelled   = "Cancelled"
+	LegStatusFailed      = "Failed"
+	GroupStatusActive    = "Active"
+	GroupStatusDone      = "Done"
+	GroupStatusCancelled = "Cancelled"
+)
+
+var ErrGroupNotFound = errors.New("order group not found")
+
+// OrderLeg is one order of a group. Limit legs are exchange limit orders,
+// stop legs are client-side stops of a StopEngine. Lots is the size of the
+// current order, lots of the replaced orders are kept in FilledLots and Closing.
+type OrderLeg struct {
+	Role          string         `json:"role"`
+	Kind          string         `json:"kind"`
+	Operation     string         `json:"operation"`
+	Price         float64        `json:"price"`
+	StopType      string         `json:"stopType"`
+	StopLimit     float64        `json:"stopLimit"`
+	Lots          int            `json:"lots"`
+	Status        string         `json:"status"`
+	OrderID       string         `json:"orderId"`
+	StopID        string         `json:"stopId"`
+	OrderExecuted int            `json:"orderExecuted"`
+	FilledLots    int            `json:"filledLots"`
+	Closing       []ClosingOrder `json:"closing"`
+	Error         string         `json:"error"`
+}
+
+// ClosingOrder is a cancelled order of a leg whose final executed lots are not known yet
+type ClosingOrder struct {
+	OrderID      string `json:"orderId"`
+	ExecutedLots int    `json:"executedLots"`
+}
+
+type OrderGroup struct {
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	FIGI      string     `json:"figi"`
+	Lots      int        `json:"lots"`
+	Status    string     `json:"status"`
+	Legs      []OrderLeg `json:"legs"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// ExecutedLots returns all lots executed by the leg including replaced orders
+func (l *OrderLeg) ExecutedLots() (rvLots int) {
+
+	rvLots = l.FilledLots + l.OrderExecuted
+
+	for _, lsClosing := range l.Closing {
+		rvLots += lsClosing.ExecutedLots
+	}
+
+	return
+
+}
+
+func (l *OrderLeg) isActive() bool {
+
+	return l.Status == LegStatusActive
+
+}
+
+func (l *OrderLeg) openLots() int {
+
+	if !l.isActive() {
+		return 0
+	}
+
+	return l.Lots - l.OrderExecuted
+
+}
+
+// OrderGroupStore persists the order groups between restarts
+type OrderGroupStore interface {
+	LoadGroups() ([]OrderGroup, error)
+	SaveGroups([]OrderGroup) error
+}
+
+// FileOrderGroupStore keeps the order groups in a JSON file replaced atomically on every save
+type FileOrderGroupStore struct {
+	mvPath string
+}
+
+func NewFileOrderGroupStore(ivPath string) *FileOrderGroupStore {
+
+	return &FileOrderGroupStore{mvPath: ivPath}
+
+}
+
+func (s *FileOrderGroupStore) LoadGroups() (rtGroups []OrderGroup, roError error) {
+
+	roError = readJSONFile(s.mvPath, &rtGroups)
+
+	return
+
+}
+
+func (s *FileOrderGroupStore) SaveGroups(itGroups []OrderGroup) (roError error) {
+
+	roError = writeJSONFile(s.mvPath, itGroups)
+
+	return
+
+}
+
+// OrderGroupOption configures an OrderGroupManager created by NewOrderGroupManager
+type OrderGroupOption func(*OrderGroupManager)
+
+func WithGroupPollInterval(ivInterval time.Duration) OrderGroupOption {
+	return func(m *OrderGroupManager) {
+		m.mvPollInterval = ivInterval
+	}
+}
+
+func WithGroupLogger(ioLogger *log.Logger) OrderGroupOption {
+	return func(m *OrderGroupManager) {
+		m.moLogger = ioLogger
+	}
+}
+
+// OrderGroupManager maintains OCO and bracket groups. Run polls the orders,
+// cancels the sibling legs when a leg fills and resizes them on partial fills.
+// Stop legs need a StopEngine which must be running to fire them.
+type OrderGroupManager struct {
+	moClient       *Client
+	moStops        *StopEngine
+	moStore        OrderGroupStore
+	moTracker      *OrderTracker
+	mvPollInterval time.Duration
+	moLogger       *log.Logger
+	moMutex        sync.Mutex
+	mtGroups       map[string]*OrderGroup
+	mtStates       map[string]*orderTrackState
+}
+
+func NewOrderGroupManager(ioClient *Client, ioStops *StopEngine, ioStore OrderGroupStore, itOptions ...OrderGroupOption) (roManager *OrderGroupManager, roError error) {
+
+	lsManager := OrderGroupManager{}
+
+	lsManager.moClient = ioClient
+	lsManager.moStops = ioStops
+	lsManager.moStore = ioStore
+	lsManager.moTracker = ioClient.NewOrderTracker()
+	lsManager.mvPollInterval = 2 * time.Second
+	lsManager.mtGroups = map[string]*OrderGroup{}
+	lsManager.mtStates = map[string]*orderTrackState{}
+
+	for _, lfOption := range itOptions {
+		lfOption(&lsManager)
+	}
+
+	if ioStore != nil {
+
+		ltGroups, loError := ioStore.LoadGroups()
+
+		if loError != nil {
+			roError = loError
+			return
+		}
+
+		for lvIndex := range ltGroups {
+			lsManager.mtGroups[ltGroups[lvIndex].ID] = &ltGroups[lvIndex]
+		}
+
+	}
+
+	roManager = &lsManager
+
+	return
+
+}
+
+func (m *OrderGroupManager) logf(ivFormat string, itArgs ...interface{}) {
+
+	if m.moLogger != nil {
+		m.moLogger.Printf(ivFormat, itArgs...)
+	}
+
+}
+
+// save must be called with the mutex locked
+func (m *OrderGroupManager) save() {
+
+	if m.moStore == nil {
+		return
+	}
+
+	if loError := m.moStore.SaveGroups(m.listGroups()); loError != nil {
+		m.logf("order groups not saved: %v", loError)
+	}
+
+}
+
+func (m *OrderGroupManager) listGroups() (rtGroups []OrderGroup) {
+
+	rtGroups = make([]OrderGroup, 0, len(m.mtGroups))
+
+	for _, lsGroup := range m.mtGroups {
+		rtGroups = append(rtGroups, copyGroup(lsGroup))
+	}
+
+	sort.Slice(rtGroups, func(i, j int) bool {
+		return rtGroups[i].CreatedAt.Before(rtGroups[j].CreatedAt)
+	})
+
+	return
+
+}
+
+func copyGroup(isGroup *OrderGroup) (rsGroup OrderGroup) {
+
+	rsGroup = *isGroup
+
+	rsGroup.Legs = make([]OrderLeg, len(isGroup.Legs))
+
+	for lvIndex, lsLeg := range isGroup.Legs {
+		lsLeg.Closing = append([]ClosingOrder(nil), lsLeg.Closing...)
+		rsGroup.Legs[lvIndex] = lsLeg
+	}
+
+	return
+
+}
+
+func (m *OrderGroupManager) ListGroups() []OrderGroup {
+
+	m.moMutex.Lock()
+	defer m.moMutex.Unlock()
+
+	return m.listGroups()
+
+}
+
+func (m *OrderGroupManager) GetGroup(ivID string) (rsGroup OrderGroup, roError error) {
+
+	m.moMutex.Lock()
+	defer m.moMutex.Unlock()
+
+	lsGroup, lvFound := m.mtGroups[ivID]
+
+	if !lvFound {
+		roError = ErrGroupNotFound
+		return
+	}
+
+	rsGroup = copyGroup(lsGroup)
+
+	return
+
+}
+
+// PlaceOCO places both legs for the full lots, when one leg executes the other is reduced or cancelled
+func (m *OrderGroupManager) PlaceOCO(ioContext context.Context, ivFIGI string, ivLots int, isFirst OrderLeg, isSecond OrderLeg) (rsGroup OrderGroup, roError error) {
+
+	lsGroup := m.newGroup(OrderGroupOCO, ivFIGI, ivLots)
+
+	lsGroup.Legs = []OrderLeg{isFirst, isSecond}
+
+	roError = m.checkGroup(lsGroup)
+
+	if roError != nil {
+		return
+	}
+
+	m.moMutex.Lock()
+	defer m.moMutex.Unlock()
+
+	m.mtGroups[lsGroup.ID] = lsGroup
+
+	for lvIndex := range lsGroup.Legs {
+
+		roError = m.placeLeg(ioContext, lsGroup, &lsGroup.Legs[lvIndex], ivLots)
+
+		if roError != nil {
+			break
+		}
+
+	}
+
+	if roError != nil {
+		m.cancelGroup(ioContext, lsGroup)
+	}
+
+	m.save()
+
+	rsGroup = copyGroup(lsGroup)
+
+	return
+
+}
+
+// PlaceBracket places the entry limit order, the stop (client-side) and the
+// target (limit) exits are placed for the executed entry lots and form an OCO pair
+func (m *OrderGroupManager) PlaceBracket(ioContext context.Context, ivFIGI string, ivOperation string, ivLots int, ivEntryPrice float64, ivStopPrice float64, ivTargetPrice float64) (rsGroup OrderGroup, roError error) {
+
+	lvExit := OperationSell
+
+	if ivOperation == OperationSell {
+		lvExit = OperationBuy
+	}
+
+	lsGroup := m.newGroup(OrderGroupBracket, ivFIGI, ivLots)
+
+	lsGroup.Legs = []OrderLeg{
+		{Role: LegRoleEntry, Kind: LegKindLimit, Operation: ivOperation, Price: ivEntryPrice},
+		{Role: LegRoleStop, Kind: LegKindStop, Operation: lvExit, Price: ivStopPrice, StopType: StopTypeStopLoss},
+		{Role: LegRoleTarget, Kind: LegKindLimit, Operation: lvExit, Price: ivTargetPrice},
+	}
+
+	roError = m.checkGroup(lsGroup)
+
+	if roError != nil {
+		return
+	}
+
+	m.moMutex.Lock()
+	defer m.moMutex.Unlock()
+
+	m.mtGroups[lsGroup.ID] = lsGroup
+
+	roError = m.placeLeg(ioContext, lsGroup, &lsGroup.Legs[0], ivLots)
+
+	if roError != nil {
+		lsGroup.Status = GroupStatusCancelled
+	}
+
+	m.save()
+
+	rsGroup = copyGroup(lsGroup)
+
+	return
+
+}
+
+func (m *OrderGroupManager) newGroup(ivType string, ivFIGI string, ivLots int) (roGroup *OrderGroup) {
+
+	roGroup = &OrderGroup{}
+
+	roGroup.ID = newID()
+	roGroup.Type = ivType
+	roGroup.FIGI = ivFIGI
+	roGroup.Lots = ivLots
+	roGroup.Status = GroupStatusActive
+	roGroup.CreatedAt = time.Now()
+	roGroup.UpdatedAt = roGroup.CreatedAt
+
+	return
+
+}
+
+func (m *OrderGroupManager) checkGroup(isGroup *OrderGroup) (roError error) {
+
+	if isGroup.Lots <= 0 {
+		roError = fmt.Errorf("order group lots %v must be positive", isGroup.Lots)
+		return
+	}
+
+	for lvIndex := range isGroup.Legs {
+
+		lsLeg := &isGroup.Legs[lvIndex]
+
+		lsLeg.Status = LegStatusNew
+
+		if lsLeg.Role == "" {
+			lsLeg.Role = lsLeg.Kind
+		}
+
+		if lsLeg.Operation != OperationBuy && lsLeg.Operation != OperationSell {
+			roError = fmt.Errorf("order leg operation %q is not supported", lsLeg.Operation)
+			return
+		}
+
+		if lsLeg.Price <= 0 {
+			roError = fmt.Errorf("order leg price %v must be positive", lsLeg.Price)
+			return
+		}
+
+		switch lsLeg.Kind {
+
+		case LegKindLimit:
+
+		case LegKindStop:
+			if m.moStops == nil {
+				roError = errors.New("stop legs need a stop engine")
+				return
+			}
+			if lsLeg.StopType == "" {
+				lsLeg.StopType = StopTypeStopLoss
+			}
+
+		default:
+			roError = fmt.Errorf("order leg kind %q is not supported", lsLeg.Kind)
+			return
+
+		}
+
+	}
+
+	return
+
+}
+
+// placeLeg sends the order of the leg, must be called with the mutex locked
+func (m *OrderGroupManager) placeLeg(ioContext context.Context, isGroup *OrderGroup, isLeg *OrderLeg, ivLots int) (roError error) {
+
+	isLeg.Lots = ivLots
+	isLeg.OrderExecuted = 0
+
+	switch isLeg.Kind {
+
+	case LegKindLimit:
+
+		lsOrder, loError := m.moClient.CreateLimitOrderContext(ioContext, isGroup.FIGI, isLeg.Operation, ivLots, isLeg.Price)
+
+		if loError != nil {
+			roError = loError
+			break
+		}
+
+		isLeg.OrderID = lsOrder.ID
+		isLeg.OrderExecuted = lsOrder.ExecutedLots
+		m.mtStates[lsOrder.ID] = newPlacedOrderTrackState(lsOrder)
+
+	case LegKindStop:
+
+		lsStop, loError := m.moStops.AddStop(StopOrder{
+			Type:         isLeg.StopType,
+			FIGI:         isGroup.FIGI,
+			Operation:    isLeg.Operation,
+			Lots:         ivLots,
+			TriggerPrice: isLeg.Price,
+			LimitPrice:   isLeg.StopLimit,
+		})
+
+		if loError != nil {
+			roError = loError
+			break
+		}
+
+		isLeg.StopID = lsStop.ID
+
+	}
+
+	if roError != nil {
+		isLeg.Status = LegStatusFailed
+		isLeg.Error = roError.Error()
+		m.logf("group %v %v leg not placed: %v", isGroup.ID, isLeg.Role, roError)
+		return
+	}
+
+	isLeg.Status = LegStatusActive
+	isGroup.UpdatedAt = time.Now()
+
+	m.logf("group %v %v leg placed: %v %v lots at %v", isGroup.ID, isLeg.Role, isLeg.Operation, ivLots, isLeg.Price)
+
+	return
+
+}
+
+// cancelLeg withdraws the rest of the leg, must be called with the mutex locked
+func (m *OrderGroupManager) cancelLeg(ioContext context.Context, isGroup *OrderGroup, isLeg *OrderLeg) (roError error) {
+
+	if !isLeg.isActive() {
+		if isLeg.Status == LegStatusNew {
+			isLeg.Status = LegStatusCancelled
+		}
+		return
+	}
+
+	if isLeg.Kind == LegKindStop && isLeg.OrderID == "" {
+
+		roError = m.moStops.CancelStop(isLeg.StopID)
+
+		if roError != nil {
+			// The stop has fired meanwhile, its order is picked up by the next sync
+			return
+		}
+
+	} else {
+
+		roError = m.moClient.CancelOrderContext(ioContext, isLeg.OrderID)
+
+		if roError != nil {
+			return
+		}
+
+		// Lots executed after the last poll are counted once the order is final
+		isLeg.Closing = append(isLeg.Closing, ClosingOrder{OrderID: isLeg.OrderID, ExecutedLots: isLeg.OrderExecuted})
+
+	}
+
+	isLeg.Status = LegStatusCancelled
+	isLeg.OrderExecuted = 0
+	isGroup.UpdatedAt = time.Now()
+
+	m.logf("group %v %v leg cancelled", isGroup.ID, isLeg.Role)
+
+	return
+
+}
+
+// resizeLeg makes the open lots of the leg equal to the lots, must be called with the mutex locked
+func (m *OrderGroupManager) resizeLeg(ioContext context.Context, isGroup *OrderGroup, isLeg *OrderLeg, ivLots int) (roError error) {
+
+	if isLeg.Status == LegStatusNew {
+		if ivLots > 0 {
+			roError = m.placeLeg(ioContext, isGroup, isLeg, ivLots)
+		}
+		return
+	}
+
+	if !isLeg.isActive() || isLeg.openLots() == ivLots {
+		return
+	}
+
+	if ivLots <= 0 {
+		roError = m.cancelLeg(ioContext, isGroup, isLeg)
+		return
+	}
+
+	if isLeg.Kind == LegKindStop && isLeg.OrderID == "" {
+
+		_, roError = m.moStops.ModifyStop(isLeg.StopID, isLeg.Price, isLeg.StopLimit, ivLots)
+
+		if roError == nil {
+			isLeg.Lots = ivLots
+			m.logf("group %v %v leg resized to %v lots", isGroup.ID, isLeg.Role, ivLots)
+		}
+
+		return
+
+	}
+
+	// A triggered stop leg is a market order and is not resized
+	if isLeg.Kind == LegKindStop {
+		return
+	}
+
+	roError = m.cancelLeg(ioContext, isGroup, isLeg)
+
+	if roError != nil {
+		return
+	}
+
+	roError = m.placeLeg(ioContext, isGroup, isLeg, ivLots)
+
+	return
+
+}
+
+// CancelGroup cancels all active legs of the group
+func (m *OrderGroupManager) CancelGroup(ioContext context.Context, ivID string) (roError error) {
+
+	m.moMutex.Lock()
+	defer m.moMutex.Unlock()
+
+	lsGroup, lvFound := m.mtGroups[ivID]
+
+	if !lvFound {
+		roError = ErrGroupNotFound
+		return
+	}
+
+	roError = m.cancelGroup(ioContext, lsGroup)
+
+	m.save()
+
+	return
+
+}
+
+func (m *OrderGroupManager) cancelGroup(ioContext context.Context, isGroup *OrderGroup) (roError error) {
+
+	for lvIndex := range isGroup.Legs {
+		if loError := m.cancelLeg(ioContext, isGroup, &isGroup.Legs[lvIndex]); loError != nil && roError == nil {
+			roError = loError
+		}
+	}
+
+	if roError == nil {
+		isGroup.Status = GroupStatusCancelled
+		isGroup.UpdatedAt = time.Now()
+	}
+
+	return
+
+}
+
+// pending reports whether the group has orders to follow
+func (g *OrderGroup) pending() bool {
+
+	if g.Status == GroupStatusActive {
+		return true
+	}
+
+	for _, lsLeg := range g.Legs {
+		if len(lsLeg.Closing) > 0 {
+			return true
+		}
+	}
+
+	return false
+
+}
+
+// Run synchronizes the groups with the broker until the context is done
+func (m *OrderGroupManager) Run(ioContext context.Context) (roError error) {
+
+	loTicker := time.NewTicker(m.mvPollInterval)
+
+	defer loTicker.Stop()
+
+	for {
+
+		if loError := m.Sync(ioContext); loError != nil {
+			m.logf("order groups not synchronized: %v", loError)
+		}
+
+		select {
+		case <-loTicker.C:
+		case <-ioContext.Done():
+			roError = ioContext.Err()
+			return
+		}
+
+	}
+
+}
+
+// Sync polls the orders once and updates all active groups
+func (m *OrderGroupManager) Sync(ioContext context.Context) (roError error) {
+
+	m.moMutex.Lock()
+	defer m.moMutex.Unlock()
+
+	lvActive := false
+
+	for _, lsGroup := range m.mtGroups {
+		if lsGroup.pending() {
+			lvActive = true
+		}
+	}
+
+	if !lvActive {
+		return
+	}
+
+	ltOrders, roError := m.moClient.GetOrdersContext(ioContext)
+
+	if roError != nil {
+		return
+	}
+
+	for _, lsGroup := range m.mtGroups {
+
+		if !lsGroup.pending() {
+			continue
+		}
+
+		if loError := m.syncGroup(ioContext, lsGroup, ltOrders); loError != nil {
+			m.logf("group %v not synchronized: %v", lsGroup.ID, loError)
+		}
+
+	}
+
+	m.save()
+
+	return
+
+}
+
+func (m *OrderGroupManager) syncGroup(ioContext context.Context, isGroup *OrderGroup, itOrders []Order) (roError error) {
+
+	for lvIndex := range isGroup.Legs {
+
+		roError = m.refreshLeg(ioContext, isGroup, &isGroup.Legs[lvIndex], itOrders)
+
+		if roError != nil {
+			return
+		}
+
+	}
+
+	// Cancelled groups only collect the executions of their closing orders
+	if isGroup.Status != GroupStatusActive {
+		return
+	}
+
+	switch isGroup.Type {
+
+	case OrderGroupOCO:
+
+		lvRemaining := isGroup.Lots
+
+		for _, lsLeg := range isGroup.Legs {
+			lvRemaining -= lsLeg.ExecutedLots()
+		}
+
+		for lvIndex := range isGroup.Legs {
+
+			// Legs with other open lots than remain in the group are replaced
+			roError = m.resizeLeg(ioContext, isGroup, &isGroup.Legs[lvIndex], lvRemaining)
+
+			if roError != nil {
+				return
+			}
+
+		}
+
+	case OrderGroupBracket:
+
+		lsEntry := &isGroup.Legs[0]
+		lsStop := &isGroup.Legs[1]
+		lsTarget := &isGroup.Legs[2]
+
+		lvExited := lsStop.ExecutedLots() + lsTarget.ExecutedLots()
+
+		// Once an exit executes the rest of the entry is withdrawn
+		if lvExited > 0 && lsEntry.isActive() {
+
+			roError = m.cancelLeg(ioContext, isGroup, lsEntry)
+
+			if roError != nil {
+				return
+			}
+
+		}
+
+		lvOpen := lsEntry.ExecutedLots() - lvExited
+
+		for _, lsExit := range []*OrderLeg{lsStop, lsTarget} {
+
+			roError = m.resizeLeg(ioContext, isGroup, lsExit, lvOpen)
+
+			if roError != nil {
+				return
+			}
+
+		}
+
+	}
+
+	lvDone := true
+
+	for _, lsLeg := range isGroup.Legs {
+		if lsLeg.isActive() || len(lsLeg.Closing) > 0 {
+			lvDone = false
+		}
+	}
+
+	if lvDone {
+
+		isGroup.Status = GroupStatusDone
+
+		if isGroup.Type == OrderGroupBracket && isGroup.Legs[0].ExecutedLots() == 0 {
+			isGroup.Status = GroupStatusCancelled
+		}
+
+		isGroup.UpdatedAt = time.Now()
+
+		m.logf("group %v finished: %v", isGroup.ID, isGroup.Status)
+
+	}
+
+	return
+
+}
+
+// refreshLeg updates the executed lots and the status of the leg from the broker
+func (m *OrderGroupManager) refreshLeg(ioContext context.Context, isGroup *OrderGroup, isLeg *OrderLeg, itOrders []Order) (roError error) {
+
+	ltClosing := isLeg.Closing[:0]
+
+	for _, lsClosing := range isLeg.Closing {
+
+		lsEvent, loError := m.orderEvent(ioContext, isGroup, lsClosing.OrderID, lsClosing.ExecutedLots, itOrders)
+
+		if loError != nil {
+			roError = loError
+			return
+		}
+
+		lsClosing.ExecutedLots = lsEvent.ExecutedLots
+
+		if lsEvent.IsFinal() {
+			isLeg.FilledLots += lsClosing.ExecutedLots
+			delete(m.mtStates, lsClosing.OrderID)
+			continue
+		}
+
+		ltClosing = append(ltClosing, lsClosing)
+
+	}
+
+	isLeg.Closing = ltClosing
+
+	if !isLeg.isActive() {
+		return
+	}
+
+	if isLeg.Kind == LegKindStop && isLeg.OrderID == "" {
+
+		lsStop, loError := m.moStops.GetStop(isLeg.StopID)
+
+		if loError != nil {
+			roError = loError
+			return
+		}
+
+		switch lsStop.Status {
+
+		case StopStatusPending:
+			return
+
+		case StopStatusTriggered:
+			if lsStop.OrderID == "" {
+				return
+			}
+			isLeg.OrderID = lsStop.OrderID
+			m.mtStates[lsStop.OrderID] = &orderTrackState{OrderID: lsStop.OrderID, Started: lsStop.TriggeredAt, Seen: true, FIGI: isGroup.FIGI}
+			m.logf("group %v %v leg triggered, order %v", isGroup.ID, isLeg.Role, lsStop.OrderID)
+
+		default:
+			isLeg.Status = LegStatusFailed
+			isLeg.Error = fmt.Sprintf("stop %v is %v %v", lsStop.ID, lsStop.Status, lsStop.Error)
+			return
+
+		}
+
+	}
+
+	lsEvent, roError := m.orderEvent(ioContext, isGroup, isLeg.OrderID, isLeg.OrderExecuted, itOrders)
+
+	if roError != nil || lsEvent.Type == "" {
+		return
+	}
+
+	isLeg.OrderExecuted = lsEvent.ExecutedLots
+
+	switch lsEvent.Type {
+
+	case OrderEventFilled:
+		isLeg.Status = LegStatusFilled
+		m.logf("group %v %v leg filled", isGroup.ID, isLeg.Role)
+
+	case OrderEventCancelled:
+		isLeg.Status = LegStatusCancelled
+		m.logf("group %v %v leg cancelled outside of the group with %v lots executed", isGroup.ID, isLeg.Role, lsEvent.ExecutedLots)
+
+	case OrderEventRejected:
+		isLeg.Status = LegStatusFailed
+		m.logf("group %v %v leg rejected", isGroup.ID, isLeg.Role)
+
+	}
+
+	if lsEvent.IsFinal() {
+		isLeg.FilledLots += isLeg.OrderExecuted
+		isLeg.OrderExecuted = 0
+		delete(m.mtStates, isLeg.OrderID)
+	}
+
+	return
+
+}
+
+// orderEvent returns the current event of the order, its executed lots are
+// at least the recorded ones: an order missing from the active orders and
+// the operations has not lost its executions
+func (m *OrderGroupManager) orderEvent(ioContext context.Context, isGroup *OrderGroup, ivOrderID string, ivRecorded int, itOrders []Order) (rsEvent OrderEvent, roError error) {
+
+	lsState, lvFound := m.mtStates[ivOrderID]
+
+	// After a restart the state is rebuilt, the order was known to exist with the recorded lots
+	if !lvFound {
+		lsState = &orderTrackState{OrderID: ivOrderID, Started: isGroup.CreatedAt, Seen: true, FIGI: isGroup.FIGI}
+		lsState.Last = OrderEvent{Type: orderEventType("", ivRecorded), OrderID: ivOrderID, FIGI: isGroup.FIGI, ExecutedLots: ivRecorded}
+		m.mtStates[ivOrderID] = lsState
+	}
+
+	// An order filled at placement has no operation to wait for
+	if lsState.Last.IsFinal() {
+		rsEvent = lsState.Last
+		return
+	}
+
+	rsEvent, _, roError = m.moTracker.evaluate(ioContext, lsState, itOrders)
+
+	if roError != nil {
+		return
+	}
+
+	if rsEvent.Type == "" {
+		rsEvent = lsState.Last
+	}
+
+	if rsEvent.ExecutedLots < ivRecorded {
+		rsEvent.ExecutedLots = ivRecorded
+	}
+
+	return
+
+}
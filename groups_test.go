@@ -0,0 +1,165 @@
+package tinvestclient
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func placeTestOCO(t *testing.T, ioManager *OrderGroupManager) (rsGroup OrderGroup) {
+
+	rsGroup, loError := ioManager.PlaceOCO(context.Background(), testFIGI, 3,
+		OrderLeg{Role: LegRoleTarget, Kind: LegKindLimit, Operation: OperationSell, Price: 3100},
+		OrderLeg{Role: LegRoleStop, Kind: LegKindLimit, Operation: OperationSell, Price: 2900})
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	return
+
+}
+
+func syncTestGroup(t *testing.T, ioManager *OrderGroupManager, ivID string) (rsGroup OrderGroup) {
+
+	if loError := ioManager.Sync(context.Background()); loError != nil {
+		t.Fatal(loError)
+	}
+
+	rsGroup, loError := ioManager.GetGroup(ivID)
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	return
+
+}
+
+func TestOrderGroupOCOPartialFill(t *testing.T) {
+
+	loBroker, loClient := newTestBroker(t)
+
+	loManager, _ := NewOrderGroupManager(loClient, nil, nil)
+
+	lsGroup := placeTestOCO(t, loManager)
+
+	loBroker.fill("1", 1)
+
+	lsGroup = syncTestGroup(t, loManager, lsGroup.ID)
+
+	// The sibling is replaced by an order for the lots left in the group
+	ltPlacements := loBroker.placements()
+
+	if len(ltPlacements) != 3 || ltPlacements[2].Lots != 2 || ltPlacements[2].Price != 2900 {
+		t.Fatalf("placements %+v, want the second leg placed again for 2 lots", ltPlacements)
+	}
+
+	if lsLeg := lsGroup.Legs[0]; lsLeg.OrderExecuted != 1 || lsLeg.Status != LegStatusActive {
+		t.Errorf("first leg %+v, want active with 1 lot executed", lsLeg)
+	}
+
+	if lsLeg := lsGroup.Legs[1]; lsLeg.OrderID != "3" || lsLeg.Lots != 2 || len(lsLeg.Closing) != 1 {
+		t.Errorf("second leg %+v, want order 3 for 2 lots closing order 2", lsLeg)
+	}
+
+	// The cancelled order is booked without executions
+	lsGroup = syncTestGroup(t, loManager, lsGroup.ID)
+
+	if lsLeg := lsGroup.Legs[1]; len(lsLeg.Closing) != 0 || lsLeg.ExecutedLots() != 0 {
+		t.Errorf("second leg %+v, want the closing order resolved", lsLeg)
+	}
+
+	if lvLots := len(loBroker.placements()); lvLots != 3 {
+		t.Errorf("%v placements, want no more", lvLots)
+	}
+
+}
+
+func TestOrderGroupOCOFill(t *testing.T) {
+
+	loBroker, loClient := newTestBroker(t)
+
+	loManager, _ := NewOrderGroupManager(loClient, nil, nil)
+
+	lsGroup := placeTestOCO(t, loManager)
+
+	loBroker.fill("2", 3)
+
+	lsGroup = syncTestGroup(t, loManager, lsGroup.ID)
+
+	if lsGroup.Legs[1].Status != LegStatusFilled || lsGroup.Legs[1].FilledLots != 3 {
+		t.Errorf("second leg %+v, want filled", lsGroup.Legs[1])
+	}
+
+	if lsGroup.Legs[0].Status != LegStatusCancelled {
+		t.Errorf("first leg %+v, want cancelled", lsGroup.Legs[0])
+	}
+
+	if lsGroup = syncTestGroup(t, loManager, lsGroup.ID); lsGroup.Status != GroupStatusDone {
+		t.Errorf("group %+v, want done", lsGroup)
+	}
+
+}
+
+func TestOrderGroupRestart(t *testing.T) {
+
+	loBroker, loClient := newTestBroker(t)
+
+	loStore := NewFileOrderGroupStore(filepath.Join(t.TempDir(), "groups.json"))
+
+	loManager, _ := NewOrderGroupManager(loClient, nil, loStore)
+
+	lsGroup := placeTestOCO(t, loManager)
+
+	loBroker.fill("1", 1)
+
+	syncTestGroup(t, loManager, lsGroup.ID)
+	syncTestGroup(t, loManager, lsGroup.ID)
+
+	// While the process is down the first leg fills, its operation is not booked yet
+	loBroker.moMutex.Lock()
+	loBroker.removeOrder("1")
+	loBroker.moMutex.Unlock()
+
+	loManager, loError := NewOrderGroupManager(loClient, nil, loStore)
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	for lvSync := 0; lvSync < 10; lvSync++ {
+		lsGroup = syncTestGroup(t, loManager, lsGroup.ID)
+	}
+
+	if lsLeg := lsGroup.Legs[0]; lsLeg.Status != LegStatusActive || lsLeg.ExecutedLots() != 1 {
+		t.Errorf("first leg %+v, want active with the recorded lot", lsLeg)
+	}
+
+	if lsLeg := lsGroup.Legs[1]; lsLeg.Status != LegStatusActive || lsLeg.Lots != 2 {
+		t.Errorf("second leg %+v, want active for 2 lots", lsLeg)
+	}
+
+	if lvLots := len(loBroker.placements()); lvLots != 3 {
+		t.Errorf("%v placements, want no resize", lvLots)
+	}
+
+	loBroker.moMutex.Lock()
+	loBroker.addOperation("1", testFIGI, OperationSell, 3, 3, 3100)
+	loBroker.moMutex.Unlock()
+
+	lsGroup = syncTestGroup(t, loManager, lsGroup.ID)
+
+	if lsLeg := lsGroup.Legs[0]; lsLeg.Status != LegStatusFilled || lsLeg.FilledLots != 3 {
+		t.Errorf("first leg %+v, want filled with 3 lots", lsLeg)
+	}
+
+	if lsLeg := lsGroup.Legs[1]; lsLeg.Status != LegStatusCancelled {
+		t.Errorf("second leg %+v, want cancelled", lsLeg)
+	}
+
+	if lsGroup = syncTestGroup(t, loManager, lsGroup.ID); lsGroup.Status != GroupStatusDone {
+		t.Errorf("group %+v, want done", lsGroup)
+	}
+
+}
@@ -0,0 +1,219 @@
+package tinvestclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestInfo describes one attempt of an OpenAPI call. Before hooks get the
+// request part, After hooks additionally get latency, status, tracking ID and error.
+type RequestInfo struct {
+	Method string
+	Path   string
+	Params url.Values
+	// Header holds the request headers with the bearer token redacted
+	Header     http.Header
+	Attempt    int
+	Start      time.Time
+	Latency    time.Duration
+	StatusCode int
+	TrackingID string
+	Error      error
+}
+
+// Hook is called around every HTTP attempt, retries included (Attempt > 1)
+type Hook struct {
+	Before func(ioContext context.Context, isInfo *RequestInfo)
+	After  func(ioContext context.Context, isInfo *RequestInfo)
+}
+
+func newRequestInfo(ioRequest *http.Request, ivPath string, ivAttempt int) (rsInfo RequestInfo) {
+
+	rsInfo.Method = ioRequest.Method
+	rsInfo.Path = ivPath
+	rsInfo.Params = ioRequest.URL.Query()
+	rsInfo.Header = ioRequest.Header.Clone()
+	rsInfo.Attempt = ivAttempt
+	rsInfo.Start = time.Now()
+
+	if rsInfo.Header.Get("Authorization") != "" {
+		rsInfo.Header.Set("Authorization", "Bearer ***")
+	}
+
+	return
+
+}
+
+func (c *Client) runHooks(ioContext context.Context, isInfo *RequestInfo, ivAfter bool) {
+
+	for _, lsHook := range c.mtHooks {
+
+		if ivAfter && lsHook.After != nil {
+			lsHook.After(ioContext, isInfo)
+		}
+
+		if !ivAfter && lsHook.Before != nil {
+			lsHook.Before(ioContext, isInfo)
+		}
+
+	}
+
+}
+
+func parseTrackingID(ivBody []byte) string {
+
+	type ltsResponse struct {
+		TrackingID string `json:"trackingId"`
+	}
+
+	lsResponse := ltsResponse{}
+
+	if json.Unmarshal(ivBody, &lsResponse) != nil {
+		return ""
+	}
+
+	return lsResponse.TrackingID
+
+}
+
+// LoggingHook writes one key=value line per attempt, the token is never logged
+func LoggingHook(ioLogger *log.Logger) Hook {
+
+	return Hook{
+		After: func(ioContext context.Context, isInfo *RequestInfo) {
+
+			lvLine := fmt.Sprintf("method=%v path=%v params=%q attempt=%v status=%v latency=%v tracking_id=%v",
+				isInfo.Method,
+				isInfo.Path,
+				isInfo.Params.Encode(),
+				isInfo.Attempt,
+				isInfo.StatusCode,
+				isInfo.Latency,
+				isInfo.TrackingID)
+
+			if lvAuthorization := isInfo.Header.Get("Authorization"); lvAuthorization != "" {
+				lvLine = fmt.Sprintf("%v authorization=%q", lvLine, lvAuthorization)
+			}
+
+			if isInfo.Error != nil {
+				lvLine = fmt.Sprintf("%v error=%q", lvLine, isInfo.Error.Error())
+			}
+
+			ioLogger.Println(lvLine)
+
+		},
+	}
+
+}
+
+type EndpointMetrics struct {
+	Requests     int64
+	Errors       int64
+	Retries      int64
+	TotalLatency time.Duration
+	MaxLatency   time.Duration
+}
+
+// Metrics counts requests, errors, retries and latency per endpoint path
+type Metrics struct {
+	moMutex     sync.Mutex
+	mtEndpoints map[string]*EndpointMetrics
+}
+
+func NewMetrics() *Metrics {
+
+	return &Metrics{mtEndpoints: map[string]*EndpointMetrics{}}
+
+}
+
+func (m *Metrics) Hook() Hook {
+
+	return Hook{
+		After: func(ioContext context.Context, isInfo *RequestInfo) {
+
+			m.moMutex.Lock()
+			defer m.moMutex.Unlock()
+
+			lsEndpoint, lvFound := m.mtEndpoints[isInfo.Path]
+
+			if !lvFound {
+				lsEndpoint = &EndpointMetrics{}
+				m.mtEndpoints[isInfo.Path] = lsEndpoint
+			}
+
+			lsEndpoint.Requests++
+			lsEndpoint.TotalLatency += isInfo.Latency
+
+			if isInfo.Error != nil {
+				lsEndpoint.Errors++
+			}
+
+			if isInfo.Attempt > 1 {
+				lsEndpoint.Retries++
+			}
+
+			if isInfo.Latency > lsEndpoint.MaxLatency {
+				lsEndpoint.MaxLatency = isInfo.Latency
+			}
+
+		},
+	}
+
+}
+
+func (m *Metrics) Snapshot() (rtEndpoints map[string]EndpointMetrics) {
+
+	m.moMutex.Lock()
+	defer m.moMutex.Unlock()
+
+	rtEndpoints = map[string]EndpointMetrics{}
+
+	for lvPath, lsEndpoint := range m.mtEndpoints {
+		rtEndpoints[lvPath] = *lsEndpoint
+	}
+
+	return
+
+}
+
+// String formats the counters sorted by path, handy for periodic dumps
+func (m *Metrics) String() string {
+
+	ltEndpoints := m.Snapshot()
+
+	ltPaths := make([]string, 0, len(ltEndpoints))
+
+	for lvPath := range ltEndpoints {
+		ltPaths = append(ltPaths, lvPath)
+	}
+
+	sort.Strings(ltPaths)
+
+	ltLines := []string{}
+
+	for _, lvPath := range ltPaths {
+
+		lsEndpoint := ltEndpoints[lvPath]
+
+		lvAverage := time.Duration(0)
+
+		if lsEndpoint.Requests > 0 {
+			lvAverage = lsEndpoint.TotalLatency / time.Duration(lsEndpoint.Requests)
+		}
+
+		ltLines = append(ltLines, fmt.Sprintf("path=%v requests=%v errors=%v retries=%v avg_latency=%v max_latency=%v",
+			lvPath, lsEndpoint.Requests, lsEndpoint.Errors, lsEndpoint.Retries, lvAverage, lsEndpoint.MaxLatency))
+
+	}
+
+	return strings.Join(ltLines, "\n")
+
+}
@@ -0,0 +1,281 @@
+package tinvestclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// IcebergRequest describes a limit order of Lots shown to the market by clips
+// of ClipLots, the next clip is placed when the current one is filled
+type IcebergRequest struct {
+	FIGI       string
+	Operation  string
+	Lots       int
+	ClipLots   int
+	Price      float64
+	OnProgress func(IcebergState)
+}
+
+// IcebergState is the progress of an iceberg order, the lots of the current
+// clip executed so far are included and valued at the limit price
+type IcebergState struct {
+	FIGI          string    `json:"figi"`
+	Operation     string    `json:"operation"`
+	Price         float64   `json:"price"`
+	Status        string    `json:"status"`
+	Error         string    `json:"error"`
+	RequestedLots int       `json:"requestedLots"`
+	FilledLots    int       `json:"filledLots"`
+	RemainingLots int       `json:"remainingLots"`
+	AveragePrice  float64   `json:"averagePrice"`
+	Clips         int       `json:"clips"`
+	OrderID       string    `json:"orderId"`
+	Started       time.Time `json:"started"`
+	Finished      time.Time `json:"finished"`
+}
+
+// Iceberg is a running iceberg order
+type Iceberg struct {
+	moClient  *Client
+	moTracker *OrderTracker
+	msRequest IcebergRequest
+	moMutex   sync.Mutex
+	msState   IcebergState
+	mvFilled  int
+	mvValue   float64
+	mfCancel  context.CancelFunc
+	mcDone    chan struct{}
+}
+
+func (c *Client) StartIceberg(isRequest IcebergRequest) (roIceberg *Iceberg, roError error) {
+
+	if isRequest.Operation != OperationBuy && isRequest.Operation != OperationSell {
+		roError = fmt.Errorf("operation %q is not supported", isRequest.Operation)
+		return
+	}
+
+	if isRequest.Lots <= 0 || isRequest.ClipLots <= 0 {
+		roError = fmt.Errorf("lots %v and clip lots %v must be positive", isRequest.Lots, isRequest.ClipLots)
+		return
+	}
+
+	if isRequest.Price <= 0 {
+		roError = fmt.Errorf("price %v must be positive", isRequest.Price)
+		return
+	}
+
+	lsIceberg := Iceberg{}
+
+	lsIceberg.moClient = c
+	lsIceberg.moTracker = c.NewOrderTracker(WithPollInterval(time.Second))
+	lsIceberg.msRequest = isRequest
+	lsIceberg.mcDone = make(chan struct{})
+
+	lsIceberg.msState.FIGI = isRequest.FIGI
+	lsIceberg.msState.Operation = isRequest.Operation
+	lsIceberg.msState.Price = isRequest.Price
+	lsIceberg.msState.Status = ExecutionStatusRunning
+	lsIceberg.msState.RequestedLots = isRequest.Lots
+	lsIceberg.msState.RemainingLots = isRequest.Lots
+	lsIceberg.msState.Started = time.Now()
+
+	var loContext context.Context
+
+	loContext, lsIceberg.mfCancel = context.WithCancel(context.Background())
+
+	roIceberg = &lsIceberg
+
+	go roIceberg.run(loContext)
+
+	return
+
+}
+
+// State returns the current progress
+func (i *Iceberg) State() IcebergState {
+
+	i.moMutex.Lock()
+	defer i.moMutex.Unlock()
+
+	return i.msState
+
+}
+
+// Cancel cancels the current clip and stops placing new ones
+func (i *Iceberg) Cancel() {
+
+	i.mfCancel()
+
+}
+
+// Wait blocks until the iceberg order is finished and returns its final state
+func (i *Iceberg) Wait(ioContext context.Context) (rsState IcebergState, roError error) {
+
+	select {
+	case <-i.mcDone:
+	case <-ioContext.Done():
+		roError = ioContext.Err()
+	}
+
+	rsState = i.State()
+
+	if roError == nil && rsState.Error != "" {
+		roError = errors.New(rsState.Error)
+	}
+
+	return
+
+}
+
+func (i *Iceberg) progress() {
+
+	if i.msRequest.OnProgress != nil {
+		i.msRequest.OnProgress(i.State())
+	}
+
+}
+
+// update must be called with the mutex locked
+func (i *Iceberg) update(ivClipExecuted int) {
+
+	i.msState.FilledLots = i.mvFilled + ivClipExecuted
+	i.msState.RemainingLots = i.msRequest.Lots - i.msState.FilledLots
+
+	if i.msState.FilledLots > 0 {
+		i.msState.AveragePrice = (i.mvValue + float64(ivClipExecuted)*i.msRequest.Price) / float64(i.msState.FilledLots)
+	}
+
+}
+
+func (i *Iceberg) run(ioContext context.Context) {
+
+	defer close(i.mcDone)
+
+	var loError error
+
+	lvStatus := ExecutionStatusDone
+
+	for i.mvFilled < i.msRequest.Lots && ioContext.Err() == nil {
+
+		lvLots := i.msRequest.Lots - i.mvFilled
+
+		if lvLots > i.msRequest.ClipLots {
+			lvLots = i.msRequest.ClipLots
+		}
+
+		var lsEvent OrderEvent
+
+		lsEvent, loError = i.clip(ioContext, lvLots)
+
+		if loError != nil {
+			lvStatus = ExecutionStatusFailed
+			break
+		}
+
+		if lsEvent.Type == OrderEventCancelled && ioContext.Err() == nil {
+			// The clip was cancelled by someone else, e.g. in the terminal
+			lvStatus = ExecutionStatusCancelled
+			break
+		}
+
+	}
+
+	if ioContext.Err() != nil && lvStatus == ExecutionStatusDone {
+		lvStatus = ExecutionStatusCancelled
+	}
+
+	i.moMutex.Lock()
+
+	i.msState.Status = lvStatus
+	i.msState.OrderID = ""
+	i.msState.Finished = time.Now()
+
+	if loError != nil {
+		i.msState.Error = loError.Error()
+	}
+
+	i.moMutex.Unlock()
+
+	i.mfCancel()
+
+	i.progress()
+
+}
+
+// clip places one clip and follows it until it is final
+func (i *Iceberg) clip(ioContext context.Context, ivLots int) (rsEvent OrderEvent, roError error) {
+
+	lsOrder, roError := i.moClient.CreateLimitOrderContext(ioContext, i.msRequest.FIGI, i.msRequest.Operation, ivLots, i.msRequest.Price)
+
+	if roError != nil {
+		return
+	}
+
+	i.moMutex.Lock()
+	i.msState.Clips++
+	i.msState.OrderID = lsOrder.ID
+	i.update(lsOrder.ExecutedLots)
+	i.moMutex.Unlock()
+
+	i.progress()
+
+	// A clip filled at once is final, it is not mistaken for a cancelled one while its operation is missing
+	lsState := newPlacedOrderTrackState(lsOrder)
+
+	lcEvents, _ := i.moTracker.track(ioContext, lsState)
+
+	for lsEvent := range lcEvents {
+
+		rsEvent = lsEvent
+
+		i.moMutex.Lock()
+		i.update(lsEvent.ExecutedLots)
+		i.moMutex.Unlock()
+
+		i.progress()
+
+	}
+
+	if !rsEvent.IsFinal() {
+
+		// The iceberg is cancelled, the clip is withdrawn and waited for
+		loContext, lfCancel := context.WithTimeout(context.Background(), time.Minute)
+
+		defer lfCancel()
+
+		i.moClient.CancelOrderContext(loContext, lsOrder.ID)
+
+		rsEvent, roError = i.moTracker.waitForOrder(loContext, lsState)
+
+		if roError != nil {
+			return
+		}
+
+	}
+
+	if rsEvent.Type == OrderEventRejected {
+		roError = fmt.Errorf("order %v: %w", lsOrder.ID, ErrOrderRejected)
+		return
+	}
+
+	lvPrice := rsEvent.AveragePrice
+
+	if lvPrice == 0 {
+		lvPrice = i.msRequest.Price
+	}
+
+	i.moMutex.Lock()
+
+	i.mvFilled += rsEvent.ExecutedLots
+	i.mvValue += lvPrice * float64(rsEvent.ExecutedLots)
+
+	i.update(0)
+
+	i.moMutex.Unlock()
+
+	return
+
+}
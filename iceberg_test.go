@@ -0,0 +1,74 @@
+package tinvestclient
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestIcebergClipFilledAtPlacement(t *testing.T) {
+
+	loBroker, loClient := newTestBroker(t)
+
+	loBroker.mfPlace = func(ioBroker *testBroker, isPlacement testPlacement) map[string]interface{} {
+		return placedPayload(isPlacement, OrderStatusFill, isPlacement.Lots)
+	}
+
+	loIceberg, loError := loClient.StartIceberg(IcebergRequest{FIGI: testFIGI, Operation: OperationSell, Lots: 3, ClipLots: 2, Price: 3000})
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	loContext, lfCancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer lfCancel()
+
+	lsState, loError := loIceberg.Wait(loContext)
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	if lsState.Status != ExecutionStatusDone || lsState.FilledLots != 3 || lsState.Clips != 2 || lsState.AveragePrice != 3000 {
+		t.Errorf("state %+v, want done with 3 lots in 2 clips", lsState)
+	}
+
+	if lvLots := loBroker.placedLots(); lvLots != 3 {
+		t.Errorf("%v lots sent, want 3", lvLots)
+	}
+
+}
+
+func TestIcebergCancelledClip(t *testing.T) {
+
+	loBroker, loClient := newTestBroker(t)
+
+	loIceberg, loError := loClient.StartIceberg(IcebergRequest{FIGI: testFIGI, Operation: OperationBuy, Lots: 4, ClipLots: 2, Price: 3000})
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	// The first clip is partially filled and then cancelled in the terminal
+	for len(loBroker.placements()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	loBroker.fill("1", 1)
+
+	loBroker.moMutex.Lock()
+	loBroker.cancelOrder("1")
+	loBroker.moMutex.Unlock()
+
+	loContext, lfCancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer lfCancel()
+
+	lsState, _ := loIceberg.Wait(loContext)
+
+	if lsState.Status != ExecutionStatusCancelled || lsState.FilledLots != 1 || lsState.Clips != 1 {
+		t.Errorf("state %+v, want cancelled with 1 lot", lsState)
+	}
+
+}
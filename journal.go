@@ -0,0 +1,487 @@
+package tinvestclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	JournalStatePending   = "Pending"
+	JournalStateSubmitted = "Submitted"
+	JournalStateFailed    = "Failed"
+	JournalStateUnknown   = "Unknown"
+)
+
+var ErrOrderStateUnknown = errors.New("order state unknown")
+
+// JournalOrder is the state of an order submitted through the journal.
+// Pending orders have their intent recorded but no result yet, Unknown
+// orders failed in a way which does not tell whether they were placed.
+type JournalOrder struct {
+	ClientID  string    `json:"clientId"`
+	State     string    `json:"state"`
+	FIGI      string    `json:"figi"`
+	Operation string    `json:"operation"`
+	Lots      int       `json:"lots"`
+	Price     float64   `json:"price"`
+	OrderID   string    `json:"orderId"`
+	Error     string    `json:"error"`
+	Time      time.Time `json:"time"`
+	Created   time.Time `json:"created"`
+}
+
+// OrderJournal submits orders under client IDs and appends every state
+// change as a JSON line to a file, so the orders survive a crash. An order
+// whose submission failed ambiguously, e.g. by a timeout, blocks orders
+// with the same client ID or parameters until Reconcile finds it or it is
+// marked failed.
+type OrderJournal struct {
+	moClient *Client
+	moFile   *os.File
+	moMutex  sync.Mutex
+	mtOrders map[string]*JournalOrder
+}
+
+func OpenOrderJournal(ioClient *Client, ivPath string) (roJournal *OrderJournal, roError error) {
+
+	lsJournal := OrderJournal{}
+
+	lsJournal.moClient = ioClient
+	lsJournal.mtOrders = map[string]*JournalOrder{}
+
+	roError = lsJournal.load(ivPath)
+
+	if roError != nil {
+		return
+	}
+
+	lsJournal.moFile, roError = os.OpenFile(ivPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+
+	if roError != nil {
+		return
+	}
+
+	roJournal = &lsJournal
+
+	return
+
+}
+
+// load replays the journal, a torn last line of a crashed write is skipped
+func (j *OrderJournal) load(ivPath string) (roError error) {
+
+	loFile, roError := os.Open(ivPath)
+
+	if os.IsNotExist(roError) {
+		roError = nil
+		return
+	}
+
+	if roError != nil {
+		return
+	}
+
+	defer loFile.Close()
+
+	loScanner := bufio.NewScanner(loFile)
+
+	for loScanner.Scan() {
+
+		lsOrder := JournalOrder{}
+
+		if json.Unmarshal(loScanner.Bytes(), &lsOrder) != nil {
+			continue
+		}
+
+		j.mtOrders[lsOrder.ClientID] = &lsOrder
+
+	}
+
+	roError = loScanner.Err()
+
+	// The result of orders pending at the crash was lost
+	for _, lsOrder := range j.mtOrders {
+		if lsOrder.State == JournalStatePending {
+			lsOrder.State = JournalStateUnknown
+		}
+	}
+
+	return
+
+}
+
+func (j *OrderJournal) Close() error {
+
+	return j.moFile.Close()
+
+}
+
+// write appends the order state, must be called with the mutex locked
+func (j *OrderJournal) write(isOrder *JournalOrder) (roError error) {
+
+	isOrder.Time = time.Now()
+
+	lvLine, roError := json.Marshal(isOrder)
+
+	if roError != nil {
+		return
+	}
+
+	_, roError = j.moFile.Write(append(lvLine, '\n'))
+
+	if roError != nil {
+		return
+	}
+
+	roError = j.moFile.Sync()
+
+	return
+
+}
+
+func (j *OrderJournal) Orders() (rtOrders []JournalOrder) {
+
+	j.moMutex.Lock()
+	defer j.moMutex.Unlock()
+
+	for _, lsOrder := range j.mtOrders {
+		rtOrders = append(rtOrders, *lsOrder)
+	}
+
+	sort.Slice(rtOrders, func(i, j int) bool {
+		return rtOrders[i].Created.Before(rtOrders[j].Created)
+	})
+
+	return
+
+}
+
+func (j *OrderJournal) Order(ivClientID string) (rsOrder JournalOrder, roError error) {
+
+	j.moMutex.Lock()
+	defer j.moMutex.Unlock()
+
+	lsOrder, lvFound := j.mtOrders[ivClientID]
+
+	if !lvFound {
+		roError = fmt.Errorf("client order %v: %w", ivClientID, ErrNotFound)
+		return
+	}
+
+	rsOrder = *lsOrder
+
+	return
+
+}
+
+// SubmitOrder places a limit order, or a market order for a zero price, under
+// the client ID. A submitted client ID returns the recorded order again, a
+// failed one is submitted anew; an empty client ID gets a generated one.
+func (j *OrderJournal) SubmitOrder(ioContext context.Context, ivClientID string, ivFIGI string, ivOperation string, ivLots int, ivPrice float64) (rsOrder PlacedOrder, roError error) {
+
+	if ivClientID == "" {
+		ivClientID = newID()
+	}
+
+	j.moMutex.Lock()
+
+	lsOrder, roError := j.intent(ivClientID, ivFIGI, ivOperation, ivLots, ivPrice)
+
+	j.moMutex.Unlock()
+
+	if roError != nil {
+		return
+	}
+
+	if lsOrder.State == JournalStateSubmitted {
+		rsOrder = PlacedOrder{ID: lsOrder.OrderID, FIGI: lsOrder.FIGI, Operation: lsOrder.Operation, Price: lsOrder.Price, RequestedLots: lsOrder.Lots}
+		return
+	}
+
+	if ivPrice > 0 {
+		rsOrder, roError = j.moClient.CreateLimitOrderContext(ioContext, ivFIGI, ivOperation, ivLots, ivPrice)
+	} else {
+		rsOrder, roError = j.moClient.CreateMarketOrderContext(ioContext, ivFIGI, ivOperation, ivLots)
+	}
+
+	j.moMutex.Lock()
+	defer j.moMutex.Unlock()
+
+	lsOrder.OrderID = rsOrder.ID
+
+	switch {
+	case roError == nil:
+		lsOrder.State = JournalStateSubmitted
+		lsOrder.Error = ""
+	case isDefiniteOrderError(roError):
+		lsOrder.State = JournalStateFailed
+		lsOrder.Error = roError.Error()
+	default:
+		lsOrder.State = JournalStateUnknown
+		lsOrder.Error = roError.Error()
+	}
+
+	if loError := j.write(lsOrder); loError != nil && roError == nil {
+		roError = fmt.Errorf("order %v placed, journal not written: %w", rsOrder.ID, loError)
+	}
+
+	return
+
+}
+
+// intent records the order before it is sent, must be called with the mutex locked
+func (j *OrderJournal) intent(ivClientID string, ivFIGI string, ivOperation string, ivLots int, ivPrice float64) (roOrder *JournalOrder, roError error) {
+
+	if lsOrder, lvFound := j.mtOrders[ivClientID]; lvFound {
+
+		switch lsOrder.State {
+		case JournalStateSubmitted:
+			roOrder = lsOrder
+			return
+		case JournalStatePending, JournalStateUnknown:
+			roError = fmt.Errorf("client order %v: %w", ivClientID, ErrOrderStateUnknown)
+			return
+		}
+
+	}
+
+	for _, lsOrder := range j.mtOrders {
+
+		if lsOrder.State != JournalStatePending && lsOrder.State != JournalStateUnknown {
+			continue
+		}
+
+		if lsOrder.FIGI == ivFIGI && lsOrder.Operation == ivOperation && lsOrder.Lots == ivLots && isSamePrice(lsOrder.Price, ivPrice) {
+			roError = fmt.Errorf("client order %v with the same parameters: %w", lsOrder.ClientID, ErrOrderStateUnknown)
+			return
+		}
+
+	}
+
+	roOrder = &JournalOrder{}
+
+	roOrder.ClientID = ivClientID
+	roOrder.State = JournalStatePending
+	roOrder.FIGI = ivFIGI
+	roOrder.Operation = ivOperation
+	roOrder.Lots = ivLots
+	roOrder.Price = ivPrice
+	roOrder.Created = time.Now()
+
+	roError = j.write(roOrder)
+
+	if roError != nil {
+		return
+	}
+
+	j.mtOrders[ivClientID] = roOrder
+
+	return
+
+}
+
+// isDefiniteOrderError reports whether the order was surely not placed: it
+// was not sent, or the API answered it. Timeouts and server errors are not
+// definite, the order may exist.
+func isDefiniteOrderError(ioError error) bool {
+
+	loAPIError := &APIError{}
+
+	if errors.As(ioError, &loAPIError) {
+		return loAPIError.HTTPStatus < 500
+	}
+
+	return errors.Is(ioError, ErrOrderRejected) ||
+		errors.Is(ioError, ErrInvalidOrder) ||
+		isRequestNotSent(ioError)
+
+}
+
+// Reconcile searches the unknown orders in the active orders and the
+// operations by FIGI, operation, lots and price. A found order becomes
+// submitted, the others stay unknown: an order missing from the operations
+// may still show up later, only MarkFailed gives it up.
+func (j *OrderJournal) Reconcile(ioContext context.Context) (rtOrders []JournalOrder, roError error) {
+
+	j.moMutex.Lock()
+	defer j.moMutex.Unlock()
+
+	ltUnknown := []*JournalOrder{}
+	ltClaimed := map[string]bool{}
+
+	lvFrom := time.Now()
+
+	for _, lsOrder := range j.mtOrders {
+
+		if lsOrder.OrderID != "" {
+			ltClaimed[lsOrder.OrderID] = true
+		}
+
+		// Pending orders are being submitted right now
+		if lsOrder.State == JournalStateUnknown {
+			ltUnknown = append(ltUnknown, lsOrder)
+			if lsOrder.Created.Before(lvFrom) {
+				lvFrom = lsOrder.Created
+			}
+		}
+
+	}
+
+	if len(ltUnknown) == 0 {
+		return
+	}
+
+	sort.Slice(ltUnknown, func(i, j int) bool {
+		return ltUnknown[i].Created.Before(ltUnknown[j].Created)
+	})
+
+	ltOrders, roError := j.moClient.GetOrdersContext(ioContext)
+
+	if roError != nil {
+		return
+	}
+
+	// Operations of all FIGIs, dual listings are told apart by operationFIGI
+	ltOperations, roError := j.moClient.getOperations(ioContext, "", lvFrom.Add(-time.Minute), time.Now().Add(time.Minute))
+
+	if roError != nil {
+		return
+	}
+
+	for _, lsOrder := range ltUnknown {
+
+		lvOrderID := ""
+
+		for _, lsActive := range ltOrders {
+
+			if ltClaimed[lsActive.ID] || lsActive.FIGI != lsOrder.FIGI || lsActive.Operation != lsOrder.Operation || lsActive.RequestedLots != lsOrder.Lots {
+				continue
+			}
+
+			if lsOrder.Price > 0 && !isSamePrice(lsActive.Price, lsOrder.Price) {
+				continue
+			}
+
+			lvOrderID = lsActive.ID
+
+			break
+
+		}
+
+		for lvIndex := 0; lvOrderID == "" && lvIndex < len(ltOperations); lvIndex++ {
+
+			lsOperation := &ltOperations[lvIndex]
+
+			if ltClaimed[lsOperation.ID] || j.moClient.operationFIGI(lsOperation.Figi, lsOperation.Currency) != lsOrder.FIGI || lsOperation.Date.Before(lsOrder.Created.Add(-time.Minute)) {
+				continue
+			}
+
+			if j.matchOperation(ioContext, lsOrder, lsOperation) {
+				lvOrderID = lsOperation.ID
+			}
+
+		}
+
+		if lvOrderID == "" {
+			rtOrders = append(rtOrders, *lsOrder)
+			continue
+		}
+
+		ltClaimed[lvOrderID] = true
+
+		lsOrder.OrderID = lvOrderID
+		lsOrder.State = JournalStateSubmitted
+		lsOrder.Error = ""
+
+		if roError = j.write(lsOrder); roError != nil {
+			return
+		}
+
+		rtOrders = append(rtOrders, *lsOrder)
+
+	}
+
+	return
+
+}
+
+// MarkFailed gives up an unknown order which was verified not to be placed,
+// e.g. in the broker terminal, so its client ID and parameters can be submitted again
+func (j *OrderJournal) MarkFailed(ivClientID string) (roError error) {
+
+	j.moMutex.Lock()
+	defer j.moMutex.Unlock()
+
+	lsOrder, lvFound := j.mtOrders[ivClientID]
+
+	if !lvFound {
+		roError = fmt.Errorf("client order %v: %w", ivClientID, ErrNotFound)
+		return
+	}
+
+	if lsOrder.State != JournalStateUnknown {
+		roError = fmt.Errorf("client order %v is %v, not %v", ivClientID, lsOrder.State, JournalStateUnknown)
+		return
+	}
+
+	lsOrder.State = JournalStateFailed
+	lsOrder.Error = "marked failed"
+
+	roError = j.write(lsOrder)
+
+	return
+
+}
+
+// matchOperation compares the lots and, for limit orders, whether the trades are within the price
+func (j *OrderJournal) matchOperation(ioContext context.Context, isOrder *JournalOrder, isOperation *operationResponse) bool {
+
+	lvOperation := isOperation.OperationType
+
+	if lvOperation == OperationBuyCard {
+		lvOperation = OperationBuy
+	}
+
+	if lvOperation != isOrder.Operation {
+		return false
+	}
+
+	lvLot := 1
+
+	lsInstrument, loError := j.moClient.GetCachedInstrumentContext(ioContext, isOrder.FIGI)
+
+	if loError == nil && lsInstrument.Lot > 0 {
+		lvLot = lsInstrument.Lot
+	}
+
+	if int(math.Round(isOperation.Quantity/float64(lvLot))) != isOrder.Lots {
+		return false
+	}
+
+	if isOrder.Price == 0 {
+		return true
+	}
+
+	for _, lsTrade := range isOperation.Trades {
+
+		if isOrder.Operation == OperationBuy && lsTrade.Price > isOrder.Price && !isSamePrice(lsTrade.Price, isOrder.Price) {
+			return false
+		}
+
+		if isOrder.Operation == OperationSell && lsTrade.Price < isOrder.Price && !isSamePrice(lsTrade.Price, isOrder.Price) {
+			return false
+		}
+
+	}
+
+	return true
+
+}
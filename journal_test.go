@@ -0,0 +1,187 @@
+package tinvestclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestServer starts an OpenAPI stand-in answering the paths with the payloads
+func newTestServer(t *testing.T, itPayloads map[string]interface{}) (roClient *Client) {
+
+	loServer := httptest.NewServer(http.HandlerFunc(func(ioWriter http.ResponseWriter, ioRequest *http.Request) {
+
+		lvPayload, lvFound := itPayloads[ioRequest.URL.Path]
+
+		if !lvFound {
+			http.NotFound(ioWriter, ioRequest)
+			return
+		}
+
+		json.NewEncoder(ioWriter).Encode(map[string]interface{}{"trackingId": "test", "status": "Ok", "payload": lvPayload})
+
+	}))
+
+	t.Cleanup(loServer.Close)
+
+	roClient = NewClient("token", WithBaseURL(loServer.URL), WithoutRateLimit())
+
+	return
+
+}
+
+// openTestJournal writes the orders as left by a crash and opens the journal
+func openTestJournal(t *testing.T, ioClient *Client, itOrders ...JournalOrder) (roJournal *OrderJournal) {
+
+	lvPath := filepath.Join(t.TempDir(), "orders.jsonl")
+
+	loFile, loError := os.Create(lvPath)
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	for _, lsOrder := range itOrders {
+		json.NewEncoder(loFile).Encode(lsOrder)
+	}
+
+	loFile.Close()
+
+	roJournal, loError = OpenOrderJournal(ioClient, lvPath)
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	t.Cleanup(func() { roJournal.Close() })
+
+	return
+
+}
+
+func TestIsDefiniteOrderError(t *testing.T) {
+
+	ltCases := []struct {
+		Name     string
+		Error    error
+		Definite bool
+	}{
+		{"bad request", &APIError{HTTPStatus: http.StatusBadRequest}, true},
+		{"rate limit", &APIError{HTTPStatus: http.StatusTooManyRequests}, true},
+		{"server error", &APIError{HTTPStatus: http.StatusInternalServerError}, false},
+		{"gateway timeout", fmt.Errorf("order: %w", &APIError{HTTPStatus: http.StatusGatewayTimeout}), false},
+		{"rejected", fmt.Errorf("order: %w", ErrOrderRejected), true},
+		{"invalid", ErrInvalidOrder, true},
+		{"dial", &net.OpError{Op: "dial", Err: errors.New("connection refused")}, true},
+		{"read", &net.OpError{Op: "read", Err: errors.New("connection reset")}, false},
+		{"timeout", context.DeadlineExceeded, false},
+	}
+
+	for _, lsCase := range ltCases {
+		if lvDefinite := isDefiniteOrderError(lsCase.Error); lvDefinite != lsCase.Definite {
+			t.Errorf("%v: definite %v, want %v", lsCase.Name, lvDefinite, lsCase.Definite)
+		}
+	}
+
+}
+
+func TestReconcileDualListing(t *testing.T) {
+
+	lvCreated := time.Now().Add(-time.Hour)
+
+	loClient := newTestServer(t, map[string]interface{}{
+		"/orders":                []interface{}{},
+		"/market/search/by-figi": map[string]interface{}{"figi": FigiTCSG, "lot": 1},
+		"/operations": map[string]interface{}{"operations": []map[string]interface{}{
+			// The USD listing bought at the same time is a different instrument
+			{"id": "usd", "status": OperationStatusDone, "figi": FigiTCS, "currency": CurrencyUSD, "operationType": OperationBuy,
+				"quantity": 2, "date": lvCreated.Add(time.Second), "trades": []map[string]interface{}{{"price": 40}}},
+			{"id": "rub", "status": OperationStatusDone, "figi": FigiTCS, "currency": CurrencyRUB, "operationType": OperationBuy,
+				"quantity": 2, "date": lvCreated.Add(time.Second), "trades": []map[string]interface{}{{"price": 2990}}},
+		}},
+	})
+
+	loJournal := openTestJournal(t, loClient, JournalOrder{
+		ClientID:  "tcsg",
+		State:     JournalStatePending,
+		FIGI:      FigiTCSG,
+		Operation: OperationBuy,
+		Lots:      2,
+		Price:     3000,
+		Created:   lvCreated,
+	})
+
+	ltOrders, loError := loJournal.Reconcile(context.Background())
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	if len(ltOrders) != 1 || ltOrders[0].State != JournalStateSubmitted || ltOrders[0].OrderID != "rub" {
+		t.Fatalf("reconciled %+v, want submitted as rub", ltOrders)
+	}
+
+	lsOrder, loError := loJournal.SubmitOrder(context.Background(), "tcsg", FigiTCSG, OperationBuy, 2, 3000)
+
+	if loError != nil || lsOrder.ID != "rub" {
+		t.Errorf("resubmitted %+v, %v, want the recorded order", lsOrder, loError)
+	}
+
+}
+
+func TestReconcileKeepsUnknown(t *testing.T) {
+
+	loClient := newTestServer(t, map[string]interface{}{
+		"/orders":     []interface{}{},
+		"/operations": map[string]interface{}{"operations": []interface{}{}},
+	})
+
+	lsPending := JournalOrder{
+		ClientID:  "lost",
+		State:     JournalStatePending,
+		FIGI:      FigiTCSG,
+		Operation: OperationSell,
+		Lots:      1,
+		Price:     3000,
+		Created:   time.Now().Add(-24 * time.Hour),
+	}
+
+	loJournal := openTestJournal(t, loClient, lsPending)
+
+	ltOrders, loError := loJournal.Reconcile(context.Background())
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	if len(ltOrders) != 1 || ltOrders[0].State != JournalStateUnknown {
+		t.Fatalf("reconciled %+v, want unknown", ltOrders)
+	}
+
+	_, loError = loJournal.SubmitOrder(context.Background(), "", FigiTCSG, OperationSell, 1, 3000)
+
+	if !errors.Is(loError, ErrOrderStateUnknown) {
+		t.Fatalf("submit with the same parameters: %v, want %v", loError, ErrOrderStateUnknown)
+	}
+
+	if loError = loJournal.MarkFailed("lost"); loError != nil {
+		t.Fatal(loError)
+	}
+
+	if lsOrder, _ := loJournal.Order("lost"); lsOrder.State != JournalStateFailed {
+		t.Errorf("state %v, want %v", lsOrder.State, JournalStateFailed)
+	}
+
+	if loError = loJournal.MarkFailed("lost"); loError == nil {
+		t.Error("a failed order was marked failed again")
+	}
+
+}
@@ -0,0 +1,148 @@
+package tinvestclient
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+)
+
+type OperationTrade struct {
+	TradeID  string    `json:"tradeId"`
+	Time     time.Time `json:"time"`
+	Price    float64   `json:"price"`
+	Quantity float64   `json:"quantity"`
+}
+
+// OperationDetails is an operation as reported by the API, of any type and
+// status. Payment and Commission keep their sign: money paid is negative.
+// Quantity is the ordered number of securities, QuantityExecuted the traded one.
+type OperationDetails struct {
+	ID               string           `json:"id"`
+	Status           string           `json:"status"`
+	Type             string           `json:"type"`
+	Time             time.Time        `json:"time"`
+	FIGI             string           `json:"figi"`
+	InstrumentType   string           `json:"instrumentType"`
+	IsMarginCall     bool             `json:"isMarginCall"`
+	Currency         string           `json:"currency"`
+	Payment          float64          `json:"payment"`
+	Price            float64          `json:"price"`
+	Quantity         float64          `json:"quantity"`
+	QuantityExecuted float64          `json:"quantityExecuted"`
+	Commission       MoneyAmount      `json:"commission"`
+	Trades           []OperationTrade `json:"trades"`
+}
+
+func (c *Client) GetOperationDetails(ivFIGI string, ivFrom time.Time, ivTo time.Time) (rtOperations []OperationDetails, roError error) {
+
+	rtOperations, roError = c.GetOperationDetailsContext(context.Background(), ivFIGI, ivFrom, ivTo)
+
+	return
+
+}
+
+// GetOperationDetailsContext returns all operations without filtering, sorted
+// by time. Operations of a dual listing carry the FIGI of the listing.
+func (c *Client) GetOperationDetailsContext(ioContext context.Context, ivFIGI string, ivFrom time.Time, ivTo time.Time) (rtOperations []OperationDetails, roError error) {
+
+	ltResponseOperations, roError := c.getOperations(ioContext, c.operationsSource(ivFIGI), ivFrom, ivTo)
+
+	if roError != nil {
+		return
+	}
+
+	for _, lsResponseOperation := range ltResponseOperations {
+
+		lsDetails := newOperationDetails(&lsResponseOperation)
+
+		lsDetails.FIGI = c.operationFIGI(lsDetails.FIGI, lsDetails.Currency)
+
+		// The source FIGI returns the operations of all its listings
+		if ivFIGI != "" && lsDetails.FIGI != ivFIGI {
+			continue
+		}
+
+		rtOperations = append(rtOperations, lsDetails)
+
+	}
+
+	sort.SliceStable(rtOperations, func(i, j int) bool {
+		return rtOperations[i].Time.Before(rtOperations[j].Time)
+	})
+
+	return
+
+}
+
+func newOperationDetails(isResponse *operationResponse) (rsDetails OperationDetails) {
+
+	rsDetails.ID = isResponse.ID
+	rsDetails.Status = isResponse.Status
+	rsDetails.Type = isResponse.OperationType
+	rsDetails.Time = isResponse.Date
+	rsDetails.FIGI = isResponse.Figi
+	rsDetails.InstrumentType = isResponse.InstrumentType
+	rsDetails.IsMarginCall = isResponse.IsMarginCall
+	rsDetails.Currency = isResponse.Currency
+	rsDetails.Payment = isResponse.Payment
+	rsDetails.Price = isResponse.Price
+	rsDetails.Quantity = isResponse.Quantity
+	rsDetails.QuantityExecuted = isResponse.QuantityExecuted
+	rsDetails.Commission.Currency = isResponse.Commission.Currency
+	rsDetails.Commission.Value = isResponse.Commission.Value
+
+	for _, lsTrade := range isResponse.Trades {
+		rsDetails.Trades = append(rsDetails.Trades, OperationTrade{
+			TradeID:  lsTrade.TradeID,
+			Time:     lsTrade.Date,
+			Price:    lsTrade.Price,
+			Quantity: lsTrade.Quantity,
+		})
+	}
+
+	return
+
+}
+
+// SimplifyOperations is the view of GetOperations: only done operations
+// without broker commissions, BuyCard as Buy and all amounts unsigned
+func SimplifyOperations(itDetails []OperationDetails) (rtOperations []Operation) {
+
+	for _, lsDetails := range itDetails {
+
+		if lsDetails.Status != OperationStatusDone {
+			continue
+		}
+
+		if lsDetails.Type == OperationBrokerCommission {
+			continue
+		}
+
+		lsOperation := Operation{}
+
+		lsOperation.ID = lsDetails.ID
+		lsOperation.Type = lsDetails.Type
+		lsOperation.FIGI = lsDetails.FIGI
+		lsOperation.Currency = lsDetails.Currency
+		lsOperation.Time = lsDetails.Time
+		lsOperation.Quantity = lsDetails.QuantityExecuted
+		lsOperation.Price = math.Abs(lsDetails.Price)
+		lsOperation.Value = math.Abs(lsDetails.Payment)
+		lsOperation.Commission = math.Abs(lsDetails.Commission.Value)
+
+		if lsOperation.Type == OperationBuyCard {
+			lsOperation.Type = OperationBuy
+		}
+
+		rtOperations = append(rtOperations, lsOperation)
+
+	}
+
+	sort.Slice(rtOperations, func(i, j int) bool {
+		return rtOperations[i].Time.Before(rtOperations[j].Time)
+	})
+
+	return
+
+}
@@ -0,0 +1,59 @@
+package tinvestclient
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOperationDetailsDualListing(t *testing.T) {
+
+	loBroker, loClient := newTestBroker(t)
+
+	loBroker.addOperation("rub", FigiTCS, OperationBuy, 2, 2, 3000)
+	loBroker.addOperation("usd", FigiTCS, OperationBuy, 1, 1, 40)
+	loBroker.mtOperations[1]["currency"] = CurrencyUSD
+
+	lvFrom := time.Now().Add(-time.Hour)
+	lvTo := time.Now().Add(time.Hour)
+
+	ltDetails, loError := loClient.GetOperationDetails(FigiTCSG, lvFrom, lvTo)
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	if len(ltDetails) != 1 || ltDetails[0].ID != "rub" || ltDetails[0].FIGI != FigiTCSG {
+		t.Fatalf("details %+v, want the ruble operation as TCSG", ltDetails)
+	}
+
+	ltOperations, loError := loClient.GetOperations(FigiTCSG, lvFrom, lvTo)
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	if ltSimplified := SimplifyOperations(ltDetails); !reflect.DeepEqual(ltSimplified, ltOperations) {
+		t.Errorf("simplified %+v, operations %+v", ltSimplified, ltOperations)
+	}
+
+	if ltDetails, _ = loClient.GetOperationDetails(FigiTCS, lvFrom, lvTo); len(ltDetails) != 1 || ltDetails[0].ID != "usd" {
+		t.Errorf("details %+v, want the dollar operation only", ltDetails)
+	}
+
+}
+
+func TestAliasDefaultScopes(t *testing.T) {
+
+	loDefault := NewClient("token")
+	loInstalled := NewClient("token", WithAliases(DefaultAliasRegistry()))
+
+	if !reflect.DeepEqual(loDefault.mtAliasScopes, loInstalled.mtAliasScopes) {
+		t.Errorf("scopes %v, installed %v", loDefault.mtAliasScopes, loInstalled.mtAliasScopes)
+	}
+
+	if loInstalled.aliases(AliasScopeCandles) != nil || loInstalled.aliases(AliasScopePositions) != nil {
+		t.Error("candles or positions aliased by default")
+	}
+
+}
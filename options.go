@@ -0,0 +1,137 @@
+package tinvestclient
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Option configures a Client created by NewClient
+type Option func(*Client)
+
+// WithBaseURL points the client to another OpenAPI endpoint (proxy, mock server)
+func WithBaseURL(ivURL string) Option {
+	return func(c *Client) {
+		if !strings.HasSuffix(ivURL, "/") {
+			ivURL = ivURL + "/"
+		}
+		c.mvUrl = ivURL
+	}
+}
+
+// WithHTTPClient replaces the HTTP client used for all requests
+func WithHTTPClient(ioHTTPClient *http.Client) Option {
+	return func(c *Client) {
+		c.moHTTPClient = ioHTTPClient
+	}
+}
+
+// WithTransport sets the RoundTripper without modifying a client passed to WithHTTPClient
+func WithTransport(ioTransport http.RoundTripper) Option {
+	return func(c *Client) {
+		lsHTTPClient := http.Client{}
+		if c.moHTTPClient != nil {
+			lsHTTPClient = *c.moHTTPClient
+		}
+		lsHTTPClient.Transport = ioTransport
+		c.moHTTPClient = &lsHTTPClient
+	}
+}
+
+// WithTimeout sets the timeout for calls whose context has no deadline, zero disables it
+func WithTimeout(ivTimeout time.Duration) Option {
+	return func(c *Client) {
+		c.mvTimeout = ivTimeout
+	}
+}
+
+// WithUserAgent sets the User-Agent header
+func WithUserAgent(ivUserAgent string) Option {
+	return func(c *Client) {
+		c.mvUserAgent = ivUserAgent
+	}
+}
+
+// WithHeader adds a header to every request
+func WithHeader(ivName string, ivValue string) Option {
+	return func(c *Client) {
+		if c.mtHeaders == nil {
+			c.mtHeaders = http.Header{}
+		}
+		c.mtHeaders.Add(ivName, ivValue)
+	}
+}
+
+// WithSandbox sends all requests to the sandbox and enables the Sandbox* methods.
+// Use WithBaseURL after it to point to a local sandbox stand-in.
+func WithSandbox() Option {
+	return func(c *Client) {
+		c.mvUrl = SandboxURL
+		c.mvSandbox = true
+	}
+}
+
+// WithRateLimit overrides the limit of a RateLimitGroup*, zero requests removes the limit.
+// Clients derived with ForAccount share the limiter.
+func WithRateLimit(ivGroup string, ivRequests int, ivPeriod time.Duration) Option {
+	return func(c *Client) {
+		if c.moLimiter == nil {
+			c.moLimiter = newRateLimiter(nil)
+		}
+		c.moLimiter.setLimit(ivGroup, RateLimit{Requests: ivRequests, Period: ivPeriod})
+	}
+}
+
+// WithoutRateLimit disables client-side rate limiting
+func WithoutRateLimit() Option {
+	return func(c *Client) {
+		c.moLimiter = nil
+	}
+}
+
+// WithRetry enables retries of failed requests, see RetryPolicy
+func WithRetry(isPolicy RetryPolicy) Option {
+	return func(c *Client) {
+		c.moRetry = &isPolicy
+	}
+}
+
+// WithHooks adds hooks called around every request in the given order
+func WithHooks(itHooks ...Hook) Option {
+	return func(c *Client) {
+		c.mtHooks = append(c.mtHooks, itHooks...)
+	}
+}
+
+// WithStreamingURL points streamers created by the client to another WebSocket endpoint
+func WithStreamingURL(ivURL string) Option {
+	return func(c *Client) {
+		c.mvStreamURL = ivURL
+	}
+}
+
+// WithOrderValidation checks orders on the client side before sending them
+func WithOrderValidation(isValidation OrderValidation) Option {
+	return func(c *Client) {
+		c.moValidation = &isValidation
+	}
+}
+
+// WithAliases replaces the instrument alias registry and sets the scopes it
+// applies to, operations only when none are given as for the default
+// DefaultAliasRegistry. Candles of a source FIGI are prices of another
+// listing, AliasScopeCandles must be given explicitly. A nil registry
+// disables aliases.
+func WithAliases(ioRegistry *AliasRegistry, itScopes ...string) Option {
+	return func(c *Client) {
+		c.moAliases = ioRegistry
+		c.mtAliasScopes = defaultAliasScopes()
+		if len(itScopes) == 0 {
+			return
+		}
+		c.mtAliasScopes = map[string]bool{}
+		for _, lvScope := range itScopes {
+			c.mtAliasScopes[lvScope] = true
+		}
+	}
+}
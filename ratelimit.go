@@ -0,0 +1,148 @@
+package tinvestclient
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RateLimitGroupMarket     = "market"
+	RateLimitGroupOrders     = "orders"
+	RateLimitGroupPortfolio  = "portfolio"
+	RateLimitGroupOperations = "operations"
+	RateLimitGroupOther      = "other"
+)
+
+// RateLimit allows Requests calls per Period, bursts up to Requests are permitted
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+func DefaultRateLimits() map[string]RateLimit {
+
+	return map[string]RateLimit{
+		RateLimitGroupMarket:     {Requests: 240, Period: time.Minute},
+		RateLimitGroupOrders:     {Requests: 100, Period: time.Minute},
+		RateLimitGroupPortfolio:  {Requests: 120, Period: time.Minute},
+		RateLimitGroupOperations: {Requests: 120, Period: time.Minute},
+		RateLimitGroupOther:      {Requests: 120, Period: time.Minute},
+	}
+
+}
+
+type rateLimiter struct {
+	moMutex   sync.Mutex
+	mtBuckets map[string]*rateBucket
+}
+
+type rateBucket struct {
+	mvTokens   float64
+	mvCapacity float64
+	mvRate     float64
+	mvUpdated  time.Time
+}
+
+func newRateLimiter(itLimits map[string]RateLimit) (roLimiter *rateLimiter) {
+
+	roLimiter = &rateLimiter{mtBuckets: map[string]*rateBucket{}}
+
+	for lvGroup, lsLimit := range itLimits {
+		roLimiter.setLimit(lvGroup, lsLimit)
+	}
+
+	return
+
+}
+
+func (l *rateLimiter) setLimit(ivGroup string, isLimit RateLimit) {
+
+	l.moMutex.Lock()
+	defer l.moMutex.Unlock()
+
+	if isLimit.Requests <= 0 || isLimit.Period <= 0 {
+		delete(l.mtBuckets, ivGroup)
+		return
+	}
+
+	l.mtBuckets[ivGroup] = &rateBucket{
+		mvTokens:   float64(isLimit.Requests),
+		mvCapacity: float64(isLimit.Requests),
+		mvRate:     float64(isLimit.Requests) / isLimit.Period.Seconds(),
+		mvUpdated:  time.Now(),
+	}
+
+}
+
+// wait blocks until a request of the group may be sent or the context is done
+func (l *rateLimiter) wait(ioContext context.Context, ivGroup string) (roError error) {
+
+	l.moMutex.Lock()
+
+	lsBucket, lvFound := l.mtBuckets[ivGroup]
+
+	if !lvFound {
+		l.moMutex.Unlock()
+		return
+	}
+
+	lvNow := time.Now()
+
+	lsBucket.mvTokens += lvNow.Sub(lsBucket.mvUpdated).Seconds() * lsBucket.mvRate
+	lsBucket.mvUpdated = lvNow
+
+	if lsBucket.mvTokens > lsBucket.mvCapacity {
+		lsBucket.mvTokens = lsBucket.mvCapacity
+	}
+
+	// Reserve the token in advance, the balance may become negative
+	lsBucket.mvTokens--
+
+	lvDelay := time.Duration(0)
+
+	if lsBucket.mvTokens < 0 {
+		lvDelay = time.Duration(-lsBucket.mvTokens / lsBucket.mvRate * float64(time.Second))
+	}
+
+	l.moMutex.Unlock()
+
+	if lvDelay == 0 {
+		return
+	}
+
+	loTimer := time.NewTimer(lvDelay)
+
+	defer loTimer.Stop()
+
+	select {
+
+	case <-loTimer.C:
+
+	case <-ioContext.Done():
+
+		l.moMutex.Lock()
+		lsBucket.mvTokens++
+		l.moMutex.Unlock()
+
+		roError = ioContext.Err()
+
+	}
+
+	return
+
+}
+
+func rateLimitGroup(ivPath string) string {
+
+	lvGroup := strings.SplitN(ivPath, "/", 2)[0]
+
+	switch lvGroup {
+	case RateLimitGroupMarket, RateLimitGroupOrders, RateLimitGroupPortfolio, RateLimitGroupOperations:
+		return lvGroup
+	}
+
+	return RateLimitGroupOther
+
+}
@@ -0,0 +1,198 @@
+package tinvestclient
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ReplaceResult reports how a replacement ended. Replaced is false when the
+// old order was filled before it could be cancelled or nothing was left to place.
+type ReplaceResult struct {
+	OldOrderID   string      `json:"oldOrderId"`
+	ExecutedLots int         `json:"executedLots"`
+	Replaced     bool        `json:"replaced"`
+	Reason       string      `json:"reason"`
+	Order        PlacedOrder `json:"order"`
+}
+
+// OrderFilter selects active orders, empty fields match all orders
+type OrderFilter struct {
+	FIGI      string
+	Operation string
+}
+
+type CancelResult struct {
+	Order Order `json:"order"`
+	Error error `json:"-"`
+}
+
+func (f *OrderFilter) match(isOrder *Order) bool {
+
+	return (f.FIGI == "" || f.FIGI == isOrder.FIGI) &&
+		(f.Operation == "" || f.Operation == isOrder.Operation)
+
+}
+
+func (c *Client) ReplaceOrder(ivOrderID string, ivPrice float64, ivLots int) (rsResult ReplaceResult, roError error) {
+
+	rsResult, roError = c.ReplaceOrderContext(context.Background(), ivOrderID, ivPrice, ivLots)
+
+	return
+
+}
+
+// ReplaceOrderContext cancels a limit order and places a new one. The API has
+// no amend, so the order is replaced in two steps: the lots executed by the
+// old order, including until its cancel, are subtracted from ivLots, the new
+// total size. A zero price or zero lots keep the values of the old order.
+func (c *Client) ReplaceOrderContext(ioContext context.Context, ivOrderID string, ivPrice float64, ivLots int) (rsResult ReplaceResult, roError error) {
+
+	rsResult.OldOrderID = ivOrderID
+
+	ltOrders, roError := c.GetOrdersContext(ioContext)
+
+	if roError != nil {
+		return
+	}
+
+	var lsOrder *Order
+
+	for lvIndex := range ltOrders {
+		if ltOrders[lvIndex].ID == ivOrderID {
+			lsOrder = &ltOrders[lvIndex]
+		}
+	}
+
+	if lsOrder == nil {
+		roError = fmt.Errorf("order %v is not active: %w", ivOrderID, ErrNotFound)
+		return
+	}
+
+	if ivPrice == 0 {
+		ivPrice = lsOrder.Price
+	}
+
+	if ivLots == 0 {
+		ivLots = lsOrder.RequestedLots
+	}
+
+	lvCancelError := c.CancelOrderContext(ioContext, ivOrderID)
+
+	lsState := newOrderTrackState(ivOrderID)
+
+	lsState.Seen = true
+	lsState.FIGI = lsOrder.FIGI
+	lsState.Last = OrderEvent{Type: OrderEventNew, OrderID: ivOrderID, FIGI: lsOrder.FIGI, Operation: lsOrder.Operation, Price: lsOrder.Price, RequestedLots: lsOrder.RequestedLots, ExecutedLots: lsOrder.ExecutedLots}
+
+	loTracker := c.NewOrderTracker(WithPollInterval(time.Second), WithTrackTimeout(time.Minute))
+
+	var lsEvent OrderEvent
+
+	if lvCancelError != nil {
+
+		// The order may be filled meanwhile, otherwise the cancel error is returned
+		lsEvent, _, roError = loTracker.poll(ioContext, lsState)
+
+		if roError != nil || !lsEvent.IsFinal() {
+			roError = lvCancelError
+			return
+		}
+
+	} else {
+
+		lsEvent, roError = loTracker.waitForOrder(ioContext, lsState)
+
+		if roError != nil {
+			return
+		}
+
+	}
+
+	rsResult.ExecutedLots = lsEvent.ExecutedLots
+
+	if lsEvent.Type == OrderEventFilled {
+		rsResult.Reason = "order filled before cancel"
+		return
+	}
+
+	lvLots := ivLots - lsEvent.ExecutedLots
+
+	if lvLots <= 0 {
+		rsResult.Reason = "no lots left to place"
+		return
+	}
+
+	rsResult.Order, roError = c.CreateLimitOrderContext(ioContext, lsOrder.FIGI, lsOrder.Operation, lvLots, ivPrice)
+
+	if roError != nil {
+		rsResult.Reason = "order cancelled, new order not placed"
+		return
+	}
+
+	rsResult.Replaced = true
+
+	return
+
+}
+
+func (c *Client) CancelAllOrders() (rtResults []CancelResult, roError error) {
+
+	rtResults, roError = c.CancelOrdersContext(context.Background(), OrderFilter{})
+
+	return
+
+}
+
+func (c *Client) CancelAllOrdersContext(ioContext context.Context) (rtResults []CancelResult, roError error) {
+
+	rtResults, roError = c.CancelOrdersContext(ioContext, OrderFilter{})
+
+	return
+
+}
+
+func (c *Client) CancelOrders(isFilter OrderFilter) (rtResults []CancelResult, roError error) {
+
+	rtResults, roError = c.CancelOrdersContext(context.Background(), isFilter)
+
+	return
+
+}
+
+// CancelOrdersContext cancels the matching active orders concurrently. The
+// error is set only when the orders cannot be read, the result of every
+// cancel is in its CancelResult.
+func (c *Client) CancelOrdersContext(ioContext context.Context, isFilter OrderFilter) (rtResults []CancelResult, roError error) {
+
+	ltOrders, roError := c.GetOrdersContext(ioContext)
+
+	if roError != nil {
+		return
+	}
+
+	for _, lsOrder := range ltOrders {
+		if isFilter.match(&lsOrder) {
+			rtResults = append(rtResults, CancelResult{Order: lsOrder})
+		}
+	}
+
+	loWaitGroup := sync.WaitGroup{}
+
+	for lvIndex := range rtResults {
+
+		loWaitGroup.Add(1)
+
+		go func(isResult *CancelResult) {
+			defer loWaitGroup.Done()
+			isResult.Error = c.CancelOrderContext(ioContext, isResult.Order.ID)
+		}(&rtResults[lvIndex])
+
+	}
+
+	loWaitGroup.Wait()
+
+	return
+
+}
@@ -0,0 +1,137 @@
+package tinvestclient
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls retries of failed requests. GET requests are retried on
+// network errors, 429 and 5xx responses. Other requests (order placement,
+// cancellation, sandbox) are retried only when the connection to the server
+// could not be established, so an order is never submitted twice.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	// OnRetry is called before every retry
+	OnRetry func(RetryEvent)
+}
+
+type RetryEvent struct {
+	Method  string
+	Path    string
+	Attempt int
+	Delay   time.Duration
+	Error   error
+}
+
+func DefaultRetryPolicy() RetryPolicy {
+
+	return RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  200 * time.Millisecond,
+		MaxBackoff:  5 * time.Second,
+	}
+
+}
+
+// delay reports whether the failed attempt should be retried and after which pause
+func (p *RetryPolicy) delay(ivMethod string, ivAttempt int, ioHeader http.Header, ioError error) (rvDelay time.Duration, rvRetry bool) {
+
+	if p == nil || ivAttempt >= p.MaxAttempts {
+		return
+	}
+
+	if errors.Is(ioError, context.Canceled) || errors.Is(ioError, context.DeadlineExceeded) {
+		return
+	}
+
+	if ivMethod == http.MethodGet {
+		rvRetry = isTransientError(ioError)
+	} else {
+		rvRetry = isRequestNotSent(ioError)
+	}
+
+	if !rvRetry {
+		return
+	}
+
+	rvDelay = p.MinBackoff << uint(ivAttempt-1)
+
+	if p.MaxBackoff > 0 && (rvDelay > p.MaxBackoff || rvDelay < 0) {
+		rvDelay = p.MaxBackoff
+	}
+
+	// Full jitter on the upper half of the backoff
+	if rvDelay > 1 {
+		rvDelay = rvDelay/2 + time.Duration(rand.Int63n(int64(rvDelay/2)+1))
+	}
+
+	lvRetryAfter := parseRetryAfter(ioHeader)
+
+	if lvRetryAfter > rvDelay {
+		rvDelay = lvRetryAfter
+	}
+
+	return
+
+}
+
+func isTransientError(ioError error) bool {
+
+	loAPIError := &APIError{}
+
+	if errors.As(ioError, &loAPIError) {
+		return loAPIError.HTTPStatus == http.StatusTooManyRequests ||
+			loAPIError.HTTPStatus >= http.StatusInternalServerError
+	}
+
+	return true
+
+}
+
+// isRequestNotSent reports whether the request provably did not reach the server
+func isRequestNotSent(ioError error) bool {
+
+	loDNSError := &net.DNSError{}
+
+	if errors.As(ioError, &loDNSError) {
+		return true
+	}
+
+	loOpError := &net.OpError{}
+
+	if errors.As(ioError, &loOpError) {
+		return loOpError.Op == "dial"
+	}
+
+	return false
+
+}
+
+func parseRetryAfter(ioHeader http.Header) (rvDelay time.Duration) {
+
+	lvValue := ioHeader.Get("Retry-After")
+
+	if lvValue == "" {
+		return
+	}
+
+	if lvSeconds, loError := strconv.Atoi(lvValue); loError == nil {
+		rvDelay = time.Duration(lvSeconds) * time.Second
+		return
+	}
+
+	if lvTime, loError := http.ParseTime(lvValue); loError == nil {
+		rvDelay = time.Until(lvTime)
+	}
+
+	return
+
+}
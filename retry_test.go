@@ -0,0 +1,99 @@
+package tinvestclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+
+	lsPolicy := RetryPolicy{MaxAttempts: 3, MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	loNotSent := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	loReset := &net.OpError{Op: "read", Err: errors.New("connection reset")}
+
+	ltCases := []struct {
+		Name    string
+		Method  string
+		Attempt int
+		Error   error
+		Retry   bool
+	}{
+		{"get server error", http.MethodGet, 1, &APIError{HTTPStatus: http.StatusInternalServerError}, true},
+		{"get rate limit", http.MethodGet, 1, &APIError{HTTPStatus: http.StatusTooManyRequests}, true},
+		{"get bad request", http.MethodGet, 1, &APIError{HTTPStatus: http.StatusBadRequest}, false},
+		{"get reset", http.MethodGet, 2, loReset, true},
+		{"get last attempt", http.MethodGet, 3, loReset, false},
+		{"get canceled", http.MethodGet, 1, context.Canceled, false},
+		{"post not sent", http.MethodPost, 1, loNotSent, true},
+		{"post last attempt", http.MethodPost, 3, loNotSent, false},
+		{"post server error", http.MethodPost, 1, &APIError{HTTPStatus: http.StatusServiceUnavailable}, false},
+		{"post rate limit", http.MethodPost, 1, &APIError{HTTPStatus: http.StatusTooManyRequests}, false},
+		{"post reset", http.MethodPost, 1, loReset, false},
+		{"post timeout", http.MethodPost, 1, context.DeadlineExceeded, false},
+	}
+
+	for _, lsCase := range ltCases {
+
+		lvDelay, lvRetry := lsPolicy.delay(lsCase.Method, lsCase.Attempt, nil, lsCase.Error)
+
+		if lvRetry != lsCase.Retry {
+			t.Errorf("%v: retry %v, want %v", lsCase.Name, lvRetry, lsCase.Retry)
+		}
+
+		if lvRetry && (lvDelay < 50*time.Millisecond || lvDelay > time.Second) {
+			t.Errorf("%v: delay %v out of the backoff", lsCase.Name, lvDelay)
+		}
+
+	}
+
+	lvDelay, _ := lsPolicy.delay(http.MethodGet, 1, http.Header{"Retry-After": {"2"}}, &APIError{HTTPStatus: http.StatusTooManyRequests})
+
+	if lvDelay != 2*time.Second {
+		t.Errorf("delay %v, want Retry-After 2s", lvDelay)
+	}
+
+	if _, lvRetry := (*RetryPolicy)(nil).delay(http.MethodGet, 1, nil, loReset); lvRetry {
+		t.Error("retried without a policy")
+	}
+
+}
+
+func TestRetryPostNotRepeated(t *testing.T) {
+
+	lvRequests := int32(0)
+
+	loServer := httptest.NewServer(http.HandlerFunc(func(ioWriter http.ResponseWriter, ioRequest *http.Request) {
+		atomic.AddInt32(&lvRequests, 1)
+		http.Error(ioWriter, `{"trackingId":"test","status":"Error","payload":{"message":"unavailable"}}`, http.StatusServiceUnavailable)
+	}))
+
+	defer loServer.Close()
+
+	loClient := NewClient("token", WithBaseURL(loServer.URL), WithoutRateLimit(), WithRetry(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond}))
+
+	_, loError := loClient.CreateLimitOrder(FigiTCSG, OperationBuy, 1, 3000)
+
+	if loError == nil {
+		t.Fatal("order placed by an unavailable server")
+	}
+
+	if lvCount := atomic.LoadInt32(&lvRequests); lvCount != 1 {
+		t.Errorf("order sent %v times, want once", lvCount)
+	}
+
+	atomic.StoreInt32(&lvRequests, 0)
+
+	loClient.GetOrders()
+
+	if lvCount := atomic.LoadInt32(&lvRequests); lvCount != 3 {
+		t.Errorf("orders requested %v times, want 3", lvCount)
+	}
+
+}
@@ -0,0 +1,214 @@
+package tinvestclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+const (
+	BrokerAccountTypeTinkoff    = "Tinkoff"
+	BrokerAccountTypeTinkoffIis = "TinkoffIis"
+)
+
+var ErrNotSandbox = errors.New("client is not in sandbox mode")
+
+func (c *Client) IsSandbox() bool {
+
+	return c.mvSandbox
+
+}
+
+func (c *Client) SandboxRegister(ivAccountType string) (rsAccount Account, roError error) {
+
+	rsAccount, roError = c.SandboxRegisterContext(context.Background(), ivAccountType)
+
+	return
+
+}
+
+func (c *Client) SandboxRegisterContext(ioContext context.Context, ivAccountType string) (rsAccount Account, roError error) {
+
+	if !c.mvSandbox {
+		roError = ErrNotSandbox
+		return
+	}
+
+	type ltsBody struct {
+		BrokerAccountType string `json:"brokerAccountType"`
+	}
+
+	lsBody := ltsBody{}
+
+	lsBody.BrokerAccountType = ivAccountType
+
+	lvBody, roError := json.Marshal(lsBody)
+
+	if roError != nil {
+		return
+	}
+
+	lvBody, roError = c.httpRequest(ioContext, http.MethodPost, "sandbox/register", nil, lvBody)
+
+	if roError != nil {
+		return
+	}
+
+	type ltsResponse struct {
+		TrackingID string `json:"trackingId"`
+		Status     string `json:"status"`
+		Payload    struct {
+			Code              string `json:"code"`
+			Message           string `json:"message"`
+			BrokerAccountType string `json:"brokerAccountType"`
+			BrokerAccountID   string `json:"brokerAccountId"`
+		} `json:"payload"`
+	}
+
+	lsResponse := ltsResponse{}
+
+	roError = json.Unmarshal(lvBody, &lsResponse)
+
+	if roError != nil {
+		return
+	}
+
+	rsAccount.ID = lsResponse.Payload.BrokerAccountID
+	rsAccount.Text = lsResponse.Payload.BrokerAccountType
+
+	return
+
+}
+
+func (c *Client) SandboxSetCurrencyBalance(ivCurrency string, ivBalance float64) (roError error) {
+
+	roError = c.SandboxSetCurrencyBalanceContext(context.Background(), ivCurrency, ivBalance)
+
+	return
+
+}
+
+func (c *Client) SandboxSetCurrencyBalanceContext(ioContext context.Context, ivCurrency string, ivBalance float64) (roError error) {
+
+	type ltsBody struct {
+		Currency string  `json:"currency"`
+		Balance  float64 `json:"balance"`
+	}
+
+	lsBody := ltsBody{}
+
+	lsBody.Currency = ivCurrency
+	lsBody.Balance = ivBalance
+
+	roError = c.sandboxRequest(ioContext, "sandbox/currencies/balance", lsBody)
+
+	return
+
+}
+
+func (c *Client) SandboxSetPositionBalance(ivFIGI string, ivBalance float64) (roError error) {
+
+	roError = c.SandboxSetPositionBalanceContext(context.Background(), ivFIGI, ivBalance)
+
+	return
+
+}
+
+func (c *Client) SandboxSetPositionBalanceContext(ioContext context.Context, ivFIGI string, ivBalance float64) (roError error) {
+
+	type ltsBody struct {
+		FIGI    string  `json:"figi"`
+		Balance float64 `json:"balance"`
+	}
+
+	lsBody := ltsBody{}
+
+	lsBody.FIGI = ivFIGI
+	lsBody.Balance = ivBalance
+
+	roError = c.sandboxRequest(ioContext, "sandbox/positions/balance", lsBody)
+
+	return
+
+}
+
+// SandboxRemove deletes the sandbox account
+func (c *Client) SandboxRemove() (roError error) {
+
+	roError = c.SandboxRemoveContext(context.Background())
+
+	return
+
+}
+
+func (c *Client) SandboxRemoveContext(ioContext context.Context) (roError error) {
+
+	roError = c.sandboxRequest(ioContext, "sandbox/remove", nil)
+
+	return
+
+}
+
+// SandboxClear removes all positions and balances of the sandbox account
+func (c *Client) SandboxClear() (roError error) {
+
+	roError = c.SandboxClearContext(context.Background())
+
+	return
+
+}
+
+func (c *Client) SandboxClearContext(ioContext context.Context) (roError error) {
+
+	roError = c.sandboxRequest(ioContext, "sandbox/clear", nil)
+
+	return
+
+}
+
+func (c *Client) sandboxRequest(ioContext context.Context, ivPath string, isBody interface{}) (roError error) {
+
+	if !c.mvSandbox {
+		roError = ErrNotSandbox
+		return
+	}
+
+	var lvBody []byte
+
+	if isBody != nil {
+
+		lvBody, roError = json.Marshal(isBody)
+
+		if roError != nil {
+			return
+		}
+
+	}
+
+	lvBody, roError = c.httpRequest(ioContext, http.MethodPost, ivPath, nil, lvBody)
+
+	if roError != nil {
+		return
+	}
+
+	type ltsResponse struct {
+		TrackingID string `json:"trackingId"`
+		Status     string `json:"status"`
+		Payload    struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"payload"`
+	}
+
+	lsResponse := ltsResponse{}
+
+	roError = json.Unmarshal(lvBody, &lsResponse)
+
+	if roError != nil {
+		return
+	}
+
+	return
+
+}
@@ -0,0 +1,692 @@
+package tinvestclient
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	StopTypeStopLoss    = "StopLoss"
+	StopTypeTakeProfit  = "TakeProfit"
+	StopTypeTrailing    = "Trailing"
+	StopStatusPending   = "Pending"
+	StopStatusTriggered = "Triggered"
+	StopStatusCancelled = "Cancelled"
+	StopStatusFailed    = "Failed"
+)
+
+var ErrStopNotFound = errors.New("stop order not found")
+
+// StopOrder is a client-side order sent to the broker when the price crosses
+// TriggerPrice. A zero LimitPrice sends a market order, otherwise a limit order.
+// Trailing stops move TriggerPrice behind Extremum, the best price seen, by
+// TrailDistance or by TrailPercent of it.
+type StopOrder struct {
+	ID            string    `json:"id"`
+	Type          string    `json:"type"`
+	FIGI          string    `json:"figi"`
+	Operation     string    `json:"operation"`
+	Lots          int       `json:"lots"`
+	TriggerPrice  float64   `json:"triggerPrice"`
+	LimitPrice    float64   `json:"limitPrice"`
+	TrailDistance float64   `json:"trailDistance"`
+	TrailPercent  float64   `json:"trailPercent"`
+	Extremum      float64   `json:"extremum"`
+	Status        string    `json:"status"`
+	OrderID       string    `json:"orderId"`
+	Error         string    `json:"error"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	TriggeredAt   time.Time `json:"triggeredAt"`
+}
+
+// StopStore persists the stop orders between restarts
+type StopStore interface {
+	LoadStops() ([]StopOrder, error)
+	SaveStops([]StopOrder) error
+}
+
+// PriceSource provides the current price for stop orders without a pushed price
+type PriceSource interface {
+	LastPrice(ioContext context.Context, ivFIGI string) (float64, error)
+}
+
+// FileStopStore keeps the stop orders in a JSON file replaced atomically on every save
+type FileStopStore struct {
+	mvPath string
+}
+
+func NewFileStopStore(ivPath string) *FileStopStore {
+
+	return &FileStopStore{mvPath: ivPath}
+
+}
+
+func (s *FileStopStore) LoadStops() (rtStops []StopOrder, roError error) {
+
+	roError = readJSONFile(s.mvPath, &rtStops)
+
+	return
+
+}
+
+func (s *FileStopStore) SaveStops(itStops []StopOrder) (roError error) {
+
+	roError = writeJSONFile(s.mvPath, itStops)
+
+	return
+
+}
+
+// readJSONFile leaves the value untouched when the file does not exist
+func readJSONFile(ivPath string, ioValue interface{}) (roError error) {
+
+	lvData, roError := os.ReadFile(ivPath)
+
+	if errors.Is(roError, os.ErrNotExist) {
+		roError = nil
+		return
+	}
+
+	if roError != nil {
+		return
+	}
+
+	roError = json.Unmarshal(lvData, ioValue)
+
+	return
+
+}
+
+// writeJSONFile writes to a temporary file and renames it, so a crash never leaves a partial file
+func writeJSONFile(ivPath string, ioValue interface{}) (roError error) {
+
+	lvData, roError := json.MarshalIndent(ioValue, "", "  ")
+
+	if roError != nil {
+		return
+	}
+
+	loFile, roError := os.CreateTemp(filepath.Dir(ivPath), filepath.Base(ivPath)+".*.tmp")
+
+	if roError != nil {
+		return
+	}
+
+	defer os.Remove(loFile.Name())
+
+	_, roError = loFile.Write(lvData)
+
+	if roError == nil {
+		roError = loFile.Sync()
+	}
+
+	if lvError := loFile.Close(); roError == nil {
+		roError = lvError
+	}
+
+	if roError != nil {
+		return
+	}
+
+	roError = os.Rename(loFile.Name(), ivPath)
+
+	return
+
+}
+
+func newID() string {
+
+	lvBytes := make([]byte, 8)
+
+	if _, loError := rand.Read(lvBytes); loError != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	return hex.EncodeToString(lvBytes)
+
+}
+
+type orderbookPriceSource struct {
+	moClient *Client
+}
+
+// OrderbookPriceSource takes the last price from GetOrderbook
+func OrderbookPriceSource(ioClient *Client) PriceSource {
+
+	return &orderbookPriceSource{moClient: ioClient}
+
+}
+
+func (s *orderbookPriceSource) LastPrice(ioContext context.Context, ivFIGI string) (rvPrice float64, roError error) {
+
+	lsOrderbook, roError := s.moClient.GetOrderbookContext(ioContext, ivFIGI, 1)
+
+	if roError != nil {
+		return
+	}
+
+	rvPrice = lsOrderbook.LastPrice
+
+	return
+
+}
+
+type candlePriceSource struct {
+	moClient *Client
+}
+
+// CandlePriceSource takes the close of the latest minute candle from GetCandles
+func CandlePriceSource(ioClient *Client) PriceSource {
+
+	return &candlePriceSource{moClient: ioClient}
+
+}
+
+func (s *candlePriceSource) LastPrice(ioContext context.Context, ivFIGI string) (rvPrice float64, roError error) {
+
+	lvTo := time.Now()
+
+	ltCandles, roError := s.moClient.GetCandlesContext(ioContext, ivFIGI, IntervalMin1, lvTo.Add(-24*time.Hour), lvTo)
+
+	if roError != nil {
+		return
+	}
+
+	if len(ltCandles) == 0 {
+		roError = fmt.Errorf("no candles for %v", ivFIGI)
+		return
+	}
+
+	rvPrice = ltCandles[len(ltCandles)-1].Close
+
+	return
+
+}
+
+// StopEngineOption configures a StopEngine created by NewStopEngine
+type StopEngineOption func(*StopEngine)
+
+// WithStopPriceSource makes Run poll prices, without it prices come only from OnPrice
+func WithStopPriceSource(ioSource PriceSource) StopEngineOption {
+	return func(e *StopEngine) {
+		e.moPrices = ioSource
+	}
+}
+
+func WithStopPollInterval(ivInterval time.Duration) StopEngineOption {
+	return func(e *StopEngine) {
+		e.mvPollInterval = ivInterval
+	}
+}
+
+// WithStopLogger replaces log.Default() as the log of stop changes, triggers
+// and trailing adjustments, a nil logger disables it
+func WithStopLogger(ioLogger *log.Logger) StopEngineOption {
+	return func(e *StopEngine) {
+		e.moLogger = ioLogger
+	}
+}
+
+// StopEngine watches prices and fires stop orders. A stop is marked as
+// triggered and saved before its order is sent, so after a crash it is never
+// sent twice; such a stop has an empty OrderID and should be checked by hand.
+type StopEngine struct {
+	moClient       *Client
+	moStore        StopStore
+	moPrices       PriceSource
+	mvPollInterval time.Duration
+	moLogger       *log.Logger
+	moMutex        sync.Mutex
+	mtStops        map[string]*StopOrder
+}
+
+func NewStopEngine(ioClient *Client, ioStore StopStore, itOptions ...StopEngineOption) (roEngine *StopEngine, roError error) {
+
+	lsEngine := StopEngine{}
+
+	lsEngine.moClient = ioClient
+	lsEngine.moStore = ioStore
+	lsEngine.mvPollInterval = 5 * time.Second
+	lsEngine.moLogger = log.Default()
+	lsEngine.mtStops = map[string]*StopOrder{}
+
+	for _, lfOption := range itOptions {
+		lfOption(&lsEngine)
+	}
+
+	if ioStore != nil {
+
+		ltStops, loError := ioStore.LoadStops()
+
+		if loError != nil {
+			roError = loError
+			return
+		}
+
+		for lvIndex := range ltStops {
+			lsEngine.mtStops[ltStops[lvIndex].ID] = &ltStops[lvIndex]
+		}
+
+	}
+
+	roEngine = &lsEngine
+
+	return
+
+}
+
+func (e *StopEngine) logf(ivFormat string, itArgs ...interface{}) {
+
+	if e.moLogger != nil {
+		e.moLogger.Printf(ivFormat, itArgs...)
+	}
+
+}
+
+// save must be called with the mutex locked
+func (e *StopEngine) save() (roError error) {
+
+	if e.moStore == nil {
+		return
+	}
+
+	roError = e.moStore.SaveStops(e.listStops())
+
+	return
+
+}
+
+func (e *StopEngine) listStops() (rtStops []StopOrder) {
+
+	rtStops = make([]StopOrder, 0, len(e.mtStops))
+
+	for _, lsStop := range e.mtStops {
+		rtStops = append(rtStops, *lsStop)
+	}
+
+	sort.Slice(rtStops, func(i, j int) bool {
+		return rtStops[i].CreatedAt.Before(rtStops[j].CreatedAt)
+	})
+
+	return
+
+}
+
+func checkStop(isStop *StopOrder) (roError error) {
+
+	if isStop.FIGI == "" {
+		roError = errors.New("stop order FIGI is empty")
+		return
+	}
+
+	if isStop.Operation != OperationBuy && isStop.Operation != OperationSell {
+		roError = fmt.Errorf("stop order operation %q is not supported", isStop.Operation)
+		return
+	}
+
+	if isStop.Lots <= 0 {
+		roError = fmt.Errorf("stop order lots %v must be positive", isStop.Lots)
+		return
+	}
+
+	switch isStop.Type {
+
+	case StopTypeStopLoss, StopTypeTakeProfit:
+		if isStop.TriggerPrice <= 0 {
+			roError = fmt.Errorf("stop order trigger price %v must be positive", isStop.TriggerPrice)
+			return
+		}
+
+	case StopTypeTrailing:
+		if (isStop.TrailDistance > 0) == (isStop.TrailPercent > 0) {
+			roError = errors.New("trailing stop needs either a trail distance or a trail percent")
+			return
+		}
+		if isStop.TrailDistance < 0 || isStop.TrailPercent < 0 || isStop.TrailPercent >= 100 {
+			roError = fmt.Errorf("trailing stop distance %v or percent %v is out of range", isStop.TrailDistance, isStop.TrailPercent)
+			return
+		}
+
+	default:
+		roError = fmt.Errorf("stop order type %q is not supported", isStop.Type)
+		return
+
+	}
+
+	if isStop.LimitPrice < 0 {
+		roError = fmt.Errorf("stop order limit price %v must not be negative", isStop.LimitPrice)
+		return
+	}
+
+	return
+
+}
+
+// AddStop registers a pending stop order and returns it with ID and status set
+func (e *StopEngine) AddStop(isStop StopOrder) (rsStop StopOrder, roError error) {
+
+	roError = checkStop(&isStop)
+
+	if roError != nil {
+		return
+	}
+
+	isStop.ID = newID()
+	isStop.Status = StopStatusPending
+	isStop.OrderID = ""
+	isStop.Error = ""
+	isStop.CreatedAt = time.Now()
+	isStop.UpdatedAt = isStop.CreatedAt
+	isStop.TriggeredAt = time.Time{}
+
+	if isStop.Type == StopTypeTrailing && isStop.Extremum > 0 {
+		isStop.TriggerPrice = trailingTrigger(&isStop)
+	}
+
+	e.moMutex.Lock()
+	defer e.moMutex.Unlock()
+
+	e.mtStops[isStop.ID] = &isStop
+
+	roError = e.save()
+
+	if roError != nil {
+		delete(e.mtStops, isStop.ID)
+		return
+	}
+
+	e.logf("stop %v added: %v %v %v lots of %v at %v", isStop.ID, isStop.Type, isStop.Operation, isStop.Lots, isStop.FIGI, isStop.TriggerPrice)
+
+	rsStop = isStop
+
+	return
+
+}
+
+func (e *StopEngine) ListStops() (rtStops []StopOrder) {
+
+	e.moMutex.Lock()
+	defer e.moMutex.Unlock()
+
+	rtStops = e.listStops()
+
+	return
+
+}
+
+func (e *StopEngine) GetStop(ivID string) (rsStop StopOrder, roError error) {
+
+	e.moMutex.Lock()
+	defer e.moMutex.Unlock()
+
+	lsStop, lvFound := e.mtStops[ivID]
+
+	if !lvFound {
+		roError = ErrStopNotFound
+		return
+	}
+
+	rsStop = *lsStop
+
+	return
+
+}
+
+// ModifyStop changes the trigger price, limit price and lots of a pending stop
+func (e *StopEngine) ModifyStop(ivID string, ivTriggerPrice float64, ivLimitPrice float64, ivLots int) (rsStop StopOrder, roError error) {
+
+	roError = e.updateStop(ivID, func(isStop *StopOrder) {
+		isStop.TriggerPrice = ivTriggerPrice
+		isStop.LimitPrice = ivLimitPrice
+		isStop.Lots = ivLots
+	})
+
+	if roError != nil {
+		return
+	}
+
+	rsStop, roError = e.GetStop(ivID)
+
+	return
+
+}
+
+func (e *StopEngine) CancelStop(ivID string) (roError error) {
+
+	roError = e.updateStop(ivID, func(isStop *StopOrder) {
+		isStop.Status = StopStatusCancelled
+	})
+
+	return
+
+}
+
+func (e *StopEngine) updateStop(ivID string, ifUpdate func(*StopOrder)) (roError error) {
+
+	e.moMutex.Lock()
+	defer e.moMutex.Unlock()
+
+	lsStop, lvFound := e.mtStops[ivID]
+
+	if !lvFound {
+		roError = ErrStopNotFound
+		return
+	}
+
+	if lsStop.Status != StopStatusPending {
+		roError = fmt.Errorf("stop order %v is %v", ivID, lsStop.Status)
+		return
+	}
+
+	lsUpdated := *lsStop
+
+	ifUpdate(&lsUpdated)
+
+	roError = checkStop(&lsUpdated)
+
+	if roError != nil {
+		return
+	}
+
+	lsUpdated.UpdatedAt = time.Now()
+
+	*lsStop = lsUpdated
+
+	roError = e.save()
+
+	e.logf("stop %v updated: status %v, trigger %v, limit %v, lots %v", lsStop.ID, lsStop.Status, lsStop.TriggerPrice, lsStop.LimitPrice, lsStop.Lots)
+
+	return
+
+}
+
+// isStopTriggered reports whether the price crossed the trigger level
+func isStopTriggered(isStop *StopOrder, ivPrice float64) bool {
+
+	if isStop.TriggerPrice <= 0 {
+		return false
+	}
+
+	lvLoss := isStop.Type != StopTypeTakeProfit
+
+	if isStop.Operation == OperationSell {
+		return (lvLoss && ivPrice <= isStop.TriggerPrice) || (!lvLoss && ivPrice >= isStop.TriggerPrice)
+	}
+
+	return (lvLoss && ivPrice >= isStop.TriggerPrice) || (!lvLoss && ivPrice <= isStop.TriggerPrice)
+
+}
+
+// OnPrice evaluates the pending stops of the instrument against the price,
+// use it to feed prices from the streaming API
+func (e *StopEngine) OnPrice(ioContext context.Context, ivFIGI string, ivPrice float64) {
+
+	if ivPrice <= 0 {
+		return
+	}
+
+	e.moMutex.Lock()
+
+	ltTriggered := []StopOrder{}
+
+	lvChanged := false
+
+	for _, lsStop := range e.mtStops {
+
+		if lsStop.FIGI != ivFIGI || lsStop.Status != StopStatusPending {
+			continue
+		}
+
+		if lsStop.Type == StopTypeTrailing && e.trail(lsStop, ivPrice) {
+			lvChanged = true
+		}
+
+		if !isStopTriggered(lsStop, ivPrice) {
+			continue
+		}
+
+		lsStop.Status = StopStatusTriggered
+		lsStop.TriggeredAt = time.Now()
+		lsStop.UpdatedAt = lsStop.TriggeredAt
+
+		e.logf("stop %v triggered at price %v (trigger %v)", lsStop.ID, ivPrice, lsStop.TriggerPrice)
+
+		ltTriggered = append(ltTriggered, *lsStop)
+
+	}
+
+	if lvChanged || len(ltTriggered) > 0 {
+
+		if loError := e.save(); loError != nil {
+
+			e.logf("stop orders not saved: %v", loError)
+
+			// An unsaved trigger would fire again after a restart, the stops wait for the next price
+			for _, lsStop := range ltTriggered {
+				e.mtStops[lsStop.ID].Status = StopStatusPending
+				e.mtStops[lsStop.ID].TriggeredAt = time.Time{}
+			}
+
+			ltTriggered = nil
+
+		}
+
+	}
+
+	e.moMutex.Unlock()
+
+	for _, lsStop := range ltTriggered {
+		e.fire(ioContext, lsStop)
+	}
+
+}
+
+func (e *StopEngine) fire(ioContext context.Context, isStop StopOrder) {
+
+	var lsOrder PlacedOrder
+	var loError error
+
+	if isStop.LimitPrice > 0 {
+		lsOrder, loError = e.moClient.CreateLimitOrderContext(ioContext, isStop.FIGI, isStop.Operation, isStop.Lots, isStop.LimitPrice)
+	} else {
+		lsOrder, loError = e.moClient.CreateMarketOrderContext(ioContext, isStop.FIGI, isStop.Operation, isStop.Lots)
+	}
+
+	e.moMutex.Lock()
+	defer e.moMutex.Unlock()
+
+	lsStop, lvFound := e.mtStops[isStop.ID]
+
+	if !lvFound {
+		return
+	}
+
+	lsStop.OrderID = lsOrder.ID
+	lsStop.UpdatedAt = time.Now()
+
+	if loError != nil {
+		lsStop.Status = StopStatusFailed
+		lsStop.Error = loError.Error()
+		e.logf("stop %v order failed: %v", lsStop.ID, loError)
+	} else {
+		e.logf("stop %v sent order %v", lsStop.ID, lsOrder.ID)
+	}
+
+	if loError := e.save(); loError != nil {
+		e.logf("stop orders not saved: %v", loError)
+	}
+
+}
+
+// Run polls the price source for instruments with pending stops until the context is done
+func (e *StopEngine) Run(ioContext context.Context) (roError error) {
+
+	loTicker := time.NewTicker(e.mvPollInterval)
+
+	defer loTicker.Stop()
+
+	for {
+
+		if e.moPrices != nil {
+			e.poll(ioContext)
+		}
+
+		select {
+		case <-loTicker.C:
+		case <-ioContext.Done():
+			roError = ioContext.Err()
+			return
+		}
+
+	}
+
+}
+
+func (e *StopEngine) poll(ioContext context.Context) {
+
+	e.moMutex.Lock()
+
+	ltFIGIs := map[string]bool{}
+
+	for _, lsStop := range e.mtStops {
+		if lsStop.Status == StopStatusPending {
+			ltFIGIs[lsStop.FIGI] = true
+		}
+	}
+
+	e.moMutex.Unlock()
+
+	for lvFIGI := range ltFIGIs {
+
+		lvPrice, loError := e.moPrices.LastPrice(ioContext, lvFIGI)
+
+		if loError != nil {
+			e.logf("price of %v not received: %v", lvFIGI, loError)
+			continue
+		}
+
+		e.OnPrice(ioContext, lvFIGI, lvPrice)
+
+	}
+
+}
+
+// FeedCandles passes the close prices of streamed candles to OnPrice until the channel is closed
+func (e *StopEngine) FeedCandles(ioContext context.Context, icCandles <-chan CandleEvent) {
+
+	for lsEvent := range icCandles {
+		e.OnPrice(ioContext, lsEvent.FIGI, lsEvent.Candle.Close)
+	}
+
+}
@@ -0,0 +1,601 @@
+package tinvestclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	streamEventCandle         = "candle"
+	streamEventOrderbook      = "orderbook"
+	streamEventInstrumentInfo = "instrument_info"
+	streamEventError          = "error"
+)
+
+type InstrumentInfo struct {
+	FIGI              string    `json:"figi"`
+	Time              time.Time `json:"time"`
+	TradeStatus       string    `json:"tradeStatus"`
+	MinPriceIncrement float64   `json:"minPriceIncrement"`
+	Lot               int       `json:"lot"`
+	AccruedInterest   float64   `json:"accruedInterest"`
+	LimitUp           float64   `json:"limitUp"`
+	LimitDown         float64   `json:"limitDown"`
+}
+
+type CandleEvent struct {
+	FIGI     string `json:"figi"`
+	Interval string `json:"interval"`
+	Candle   Candle `json:"candle"`
+}
+
+// ErrStreamerUsed is returned by a second call of Streamer.Run
+var ErrStreamerUsed = errors.New("streaming: streamer was run already")
+
+// StreamError is an error reported by the server for a subscription request
+type StreamError struct {
+	Message   string
+	RequestID string
+}
+
+func (e *StreamError) Error() string {
+
+	return "streaming: " + e.Message
+
+}
+
+// StreamerOption configures a Streamer created by Client.NewStreamer
+type StreamerOption func(*Streamer)
+
+// WithPingInterval sets how often keepalive pings are sent, a connection
+// without any incoming traffic for two intervals is considered dead
+func WithPingInterval(ivInterval time.Duration) StreamerOption {
+	return func(s *Streamer) {
+		s.mvPingInterval = ivInterval
+	}
+}
+
+// WithReconnectDelay sets the initial and the maximum pause between reconnects
+func WithReconnectDelay(ivMin time.Duration, ivMax time.Duration) StreamerOption {
+	return func(s *Streamer) {
+		s.mvReconnectMin = ivMin
+		s.mvReconnectMax = ivMax
+	}
+}
+
+// WithStreamBuffer sets the capacity of the event channels
+func WithStreamBuffer(ivSize int) StreamerOption {
+	return func(s *Streamer) {
+		s.mvBuffer = ivSize
+	}
+}
+
+// Streamer delivers market data over the streaming API. Subscriptions may be
+// made before or while Run is active and are restored after every reconnect.
+type Streamer struct {
+	mvUrl             string
+	mvToken           string
+	mvUserAgent       string
+	mvPingInterval    time.Duration
+	mvReconnectMin    time.Duration
+	mvReconnectMax    time.Duration
+	mvBuffer          int
+	moDialer          *websocket.Dialer
+	moMutex           sync.Mutex
+	moWriteMutex      sync.Mutex
+	moConn            *websocket.Conn
+	mvRun             bool
+	mtSubscriptions   map[string]streamRequest
+	mcCandles         chan CandleEvent
+	mcOrderbooks      chan Orderbook
+	mcInstrumentInfos chan InstrumentInfo
+	mcErrors          chan error
+}
+
+type streamRequest struct {
+	Event     string `json:"event"`
+	FIGI      string `json:"figi"`
+	Interval  string `json:"interval,omitempty"`
+	Depth     int    `json:"depth,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func (c *Client) NewStreamer(itOptions ...StreamerOption) (roStreamer *Streamer) {
+
+	roStreamer = &Streamer{}
+
+	roStreamer.mvUrl = c.mvStreamURL
+	roStreamer.mvToken = c.mvToken
+	roStreamer.mvUserAgent = c.mvUserAgent
+	roStreamer.mvPingInterval = 30 * time.Second
+	roStreamer.mvReconnectMin = time.Second
+	roStreamer.mvReconnectMax = 30 * time.Second
+	roStreamer.mvBuffer = 100
+	roStreamer.moDialer = &websocket.Dialer{Proxy: http.ProxyFromEnvironment, HandshakeTimeout: 30 * time.Second}
+	roStreamer.mtSubscriptions = map[string]streamRequest{}
+
+	if roStreamer.mvUrl == "" {
+		roStreamer.mvUrl = StreamingURL
+	}
+
+	for _, lfOption := range itOptions {
+		lfOption(roStreamer)
+	}
+
+	roStreamer.mcCandles = make(chan CandleEvent, roStreamer.mvBuffer)
+	roStreamer.mcOrderbooks = make(chan Orderbook, roStreamer.mvBuffer)
+	roStreamer.mcInstrumentInfos = make(chan InstrumentInfo, roStreamer.mvBuffer)
+	roStreamer.mcErrors = make(chan error, roStreamer.mvBuffer)
+
+	return
+
+}
+
+func (s *Streamer) Candles() <-chan CandleEvent {
+
+	return s.mcCandles
+
+}
+
+func (s *Streamer) Orderbooks() <-chan Orderbook {
+
+	return s.mcOrderbooks
+
+}
+
+func (s *Streamer) InstrumentInfos() <-chan InstrumentInfo {
+
+	return s.mcInstrumentInfos
+
+}
+
+// Errors delivers connection and subscription errors, they are dropped when nobody reads them
+func (s *Streamer) Errors() <-chan error {
+
+	return s.mcErrors
+
+}
+
+func (s *Streamer) SubscribeCandles(ivFIGI string, ivInterval string) (roError error) {
+
+	roError = s.subscribe(streamRequest{Event: streamEventCandle, FIGI: ivFIGI, Interval: ivInterval}, true)
+
+	return
+
+}
+
+func (s *Streamer) UnsubscribeCandles(ivFIGI string, ivInterval string) (roError error) {
+
+	roError = s.subscribe(streamRequest{Event: streamEventCandle, FIGI: ivFIGI, Interval: ivInterval}, false)
+
+	return
+
+}
+
+func (s *Streamer) SubscribeOrderbook(ivFIGI string, ivDepth int) (roError error) {
+
+	roError = s.subscribe(streamRequest{Event: streamEventOrderbook, FIGI: ivFIGI, Depth: ivDepth}, true)
+
+	return
+
+}
+
+func (s *Streamer) UnsubscribeOrderbook(ivFIGI string, ivDepth int) (roError error) {
+
+	roError = s.subscribe(streamRequest{Event: streamEventOrderbook, FIGI: ivFIGI, Depth: ivDepth}, false)
+
+	return
+
+}
+
+func (s *Streamer) SubscribeInstrumentInfo(ivFIGI string) (roError error) {
+
+	roError = s.subscribe(streamRequest{Event: streamEventInstrumentInfo, FIGI: ivFIGI}, true)
+
+	return
+
+}
+
+func (s *Streamer) UnsubscribeInstrumentInfo(ivFIGI string) (roError error) {
+
+	roError = s.subscribe(streamRequest{Event: streamEventInstrumentInfo, FIGI: ivFIGI}, false)
+
+	return
+
+}
+
+func (s *Streamer) subscribe(isRequest streamRequest, ivSubscribe bool) (roError error) {
+
+	lvKey := fmt.Sprintf("%v|%v|%v|%v", isRequest.Event, isRequest.FIGI, isRequest.Interval, isRequest.Depth)
+
+	s.moMutex.Lock()
+	defer s.moMutex.Unlock()
+
+	if ivSubscribe {
+		s.mtSubscriptions[lvKey] = isRequest
+		isRequest.Event = isRequest.Event + ":subscribe"
+	} else {
+		delete(s.mtSubscriptions, lvKey)
+		isRequest.Event = isRequest.Event + ":unsubscribe"
+	}
+
+	// Without a connection the request is sent by Run after connecting
+	if s.moConn == nil {
+		return
+	}
+
+	roError = s.write(s.moConn, isRequest)
+
+	return
+
+}
+
+func (s *Streamer) write(ioConn *websocket.Conn, isRequest streamRequest) (roError error) {
+
+	s.moWriteMutex.Lock()
+	defer s.moWriteMutex.Unlock()
+
+	roError = ioConn.SetWriteDeadline(time.Now().Add(s.mvPingInterval))
+
+	if roError != nil {
+		return
+	}
+
+	roError = ioConn.WriteJSON(isRequest)
+
+	return
+
+}
+
+// Run connects and delivers events until the context is done, reconnecting
+// after failures. The event channels are closed when Run returns, so Run may
+// be called once only; to continue create a new streamer and subscribe again.
+func (s *Streamer) Run(ioContext context.Context) (roError error) {
+
+	s.moMutex.Lock()
+
+	lvRun := s.mvRun
+	s.mvRun = true
+
+	s.moMutex.Unlock()
+
+	if lvRun {
+		roError = ErrStreamerUsed
+		return
+	}
+
+	defer func() {
+		close(s.mcCandles)
+		close(s.mcOrderbooks)
+		close(s.mcInstrumentInfos)
+		close(s.mcErrors)
+	}()
+
+	lvDelay := s.mvReconnectMin
+
+	for {
+
+		lvConnected, loError := s.runConnection(ioContext)
+
+		if ioContext.Err() != nil {
+			roError = ioContext.Err()
+			return
+		}
+
+		if loError != nil {
+			s.reportError(loError)
+		}
+
+		if lvConnected {
+			lvDelay = s.mvReconnectMin
+		}
+
+		loTimer := time.NewTimer(lvDelay)
+
+		select {
+		case <-loTimer.C:
+		case <-ioContext.Done():
+			loTimer.Stop()
+			roError = ioContext.Err()
+			return
+		}
+
+		lvDelay = lvDelay * 2
+
+		if lvDelay > s.mvReconnectMax {
+			lvDelay = s.mvReconnectMax
+		}
+
+	}
+
+}
+
+func (s *Streamer) runConnection(ioContext context.Context) (rvConnected bool, roError error) {
+
+	loHeader := http.Header{}
+
+	loHeader.Set("Authorization", "Bearer "+s.mvToken)
+
+	if s.mvUserAgent != "" {
+		loHeader.Set("User-Agent", s.mvUserAgent)
+	}
+
+	loConn, loResponse, roError := s.moDialer.DialContext(ioContext, s.mvUrl, loHeader)
+
+	if roError != nil {
+		if loResponse != nil {
+			roError = fmt.Errorf("streaming: %w, http status %v", roError, loResponse.StatusCode)
+		}
+		return
+	}
+
+	rvConnected = true
+
+	defer loConn.Close()
+
+	lvTimeout := 2 * s.mvPingInterval
+
+	loConn.SetPongHandler(func(string) error {
+		return loConn.SetReadDeadline(time.Now().Add(lvTimeout))
+	})
+
+	roError = loConn.SetReadDeadline(time.Now().Add(lvTimeout))
+
+	if roError != nil {
+		return
+	}
+
+	s.moMutex.Lock()
+
+	for _, lsRequest := range s.mtSubscriptions {
+
+		lsRequest.Event = lsRequest.Event + ":subscribe"
+
+		roError = s.write(loConn, lsRequest)
+
+		if roError != nil {
+			s.moMutex.Unlock()
+			return
+		}
+
+	}
+
+	s.moConn = loConn
+
+	s.moMutex.Unlock()
+
+	defer func() {
+		s.moMutex.Lock()
+		s.moConn = nil
+		s.moMutex.Unlock()
+	}()
+
+	lcDone := make(chan struct{})
+
+	defer close(lcDone)
+
+	go s.keepAlive(ioContext, loConn, lcDone)
+
+	for {
+
+		_, lvMessage, loError := loConn.ReadMessage()
+
+		if loError != nil {
+			roError = loError
+			return
+		}
+
+		// Any incoming message proves the connection is alive
+		roError = loConn.SetReadDeadline(time.Now().Add(lvTimeout))
+
+		if roError != nil {
+			return
+		}
+
+		roError = s.dispatch(ioContext, lvMessage)
+
+		if roError != nil {
+			return
+		}
+
+	}
+
+}
+
+// keepAlive pings the server and closes the connection when the context is done
+func (s *Streamer) keepAlive(ioContext context.Context, ioConn *websocket.Conn, icDone chan struct{}) {
+
+	loTicker := time.NewTicker(s.mvPingInterval)
+
+	defer loTicker.Stop()
+
+	for {
+
+		select {
+
+		case <-icDone:
+			return
+
+		case <-ioContext.Done():
+			ioConn.Close()
+			return
+
+		case <-loTicker.C:
+			if ioConn.WriteControl(websocket.PingMessage, nil, time.Now().Add(s.mvPingInterval)) != nil {
+				ioConn.Close()
+				return
+			}
+
+		}
+
+	}
+
+}
+
+func (s *Streamer) dispatch(ioContext context.Context, ivMessage []byte) (roError error) {
+
+	type ltsMessage struct {
+		Event   string          `json:"event"`
+		Time    time.Time       `json:"time"`
+		Payload json.RawMessage `json:"payload"`
+	}
+
+	lsMessage := ltsMessage{}
+
+	if json.Unmarshal(ivMessage, &lsMessage) != nil {
+		s.reportError(fmt.Errorf("streaming: unexpected message %s", ivMessage))
+		return
+	}
+
+	switch lsMessage.Event {
+
+	case streamEventCandle:
+
+		type ltsPayload struct {
+			O        float64   `json:"o"`
+			C        float64   `json:"c"`
+			H        float64   `json:"h"`
+			L        float64   `json:"l"`
+			V        float64   `json:"v"`
+			Time     time.Time `json:"time"`
+			Interval string    `json:"interval"`
+			Figi     string    `json:"figi"`
+		}
+
+		lsPayload := ltsPayload{}
+
+		if json.Unmarshal(lsMessage.Payload, &lsPayload) != nil {
+			s.reportError(fmt.Errorf("streaming: unexpected candle %s", lsMessage.Payload))
+			return
+		}
+
+		lsEvent := CandleEvent{}
+
+		lsEvent.FIGI = lsPayload.Figi
+		lsEvent.Interval = lsPayload.Interval
+		lsEvent.Candle = newCandle(lsPayload.Time, lsPayload.O, lsPayload.C, lsPayload.H, lsPayload.L, lsPayload.V)
+
+		select {
+		case s.mcCandles <- lsEvent:
+		case <-ioContext.Done():
+			roError = ioContext.Err()
+		}
+
+	case streamEventOrderbook:
+
+		type ltsPayload struct {
+			Figi  string       `json:"figi"`
+			Depth int          `json:"depth"`
+			Bids  [][2]float64 `json:"bids"`
+			Asks  [][2]float64 `json:"asks"`
+		}
+
+		lsPayload := ltsPayload{}
+
+		if json.Unmarshal(lsMessage.Payload, &lsPayload) != nil {
+			s.reportError(fmt.Errorf("streaming: unexpected orderbook %s", lsMessage.Payload))
+			return
+		}
+
+		lsOrderbook := Orderbook{}
+
+		lsOrderbook.FIGI = lsPayload.Figi
+		lsOrderbook.Time = lsMessage.Time
+		lsOrderbook.Depth = lsPayload.Depth
+
+		for _, ltLevel := range lsPayload.Bids {
+			lsOrderbook.Bids = append(lsOrderbook.Bids, OrderbookLevel{Price: ltLevel[0], Quantity: ltLevel[1]})
+		}
+
+		for _, ltLevel := range lsPayload.Asks {
+			lsOrderbook.Asks = append(lsOrderbook.Asks, OrderbookLevel{Price: ltLevel[0], Quantity: ltLevel[1]})
+		}
+
+		select {
+		case s.mcOrderbooks <- lsOrderbook:
+		case <-ioContext.Done():
+			roError = ioContext.Err()
+		}
+
+	case streamEventInstrumentInfo:
+
+		type ltsPayload struct {
+			Figi              string  `json:"figi"`
+			TradeStatus       string  `json:"trade_status"`
+			MinPriceIncrement float64 `json:"min_price_increment"`
+			Lot               int     `json:"lot"`
+			AccruedInterest   float64 `json:"accrued_interest"`
+			LimitUp           float64 `json:"limit_up"`
+			LimitDown         float64 `json:"limit_down"`
+		}
+
+		lsPayload := ltsPayload{}
+
+		if json.Unmarshal(lsMessage.Payload, &lsPayload) != nil {
+			s.reportError(fmt.Errorf("streaming: unexpected instrument info %s", lsMessage.Payload))
+			return
+		}
+
+		lsInfo := InstrumentInfo{}
+
+		lsInfo.FIGI = lsPayload.Figi
+		lsInfo.Time = lsMessage.Time
+		lsInfo.TradeStatus = lsPayload.TradeStatus
+		lsInfo.MinPriceIncrement = lsPayload.MinPriceIncrement
+		lsInfo.Lot = lsPayload.Lot
+		lsInfo.AccruedInterest = lsPayload.AccruedInterest
+		lsInfo.LimitUp = lsPayload.LimitUp
+		lsInfo.LimitDown = lsPayload.LimitDown
+
+		// The streaming API reports the status in snake case unlike the REST API
+		switch lsInfo.TradeStatus {
+		case "normal_trading":
+			lsInfo.TradeStatus = TradeStatusNormalTrading
+		case "not_available_for_trading":
+			lsInfo.TradeStatus = TradeStatusNotAvailableForTrading
+		}
+
+		select {
+		case s.mcInstrumentInfos <- lsInfo:
+		case <-ioContext.Done():
+			roError = ioContext.Err()
+		}
+
+	case streamEventError:
+
+		type ltsPayload struct {
+			Error     string `json:"error"`
+			RequestID string `json:"request_id"`
+		}
+
+		lsPayload := ltsPayload{}
+
+		json.Unmarshal(lsMessage.Payload, &lsPayload)
+
+		s.reportError(&StreamError{Message: lsPayload.Error, RequestID: lsPayload.RequestID})
+
+	}
+
+	return
+
+}
+
+func (s *Streamer) reportError(ioError error) {
+
+	if errors.Is(ioError, context.Canceled) {
+		return
+	}
+
+	select {
+	case s.mcErrors <- ioError:
+	default:
+	}
+
+}
@@ -0,0 +1,291 @@
+package tinvestclient
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// newStreamServer starts a streaming API stand-in passing every accepted connection to the channel
+func newStreamServer(t *testing.T) (roClient *Client, rcConns chan *websocket.Conn) {
+
+	rcConns = make(chan *websocket.Conn, 10)
+
+	loUpgrader := websocket.Upgrader{}
+
+	loServer := httptest.NewServer(http.HandlerFunc(func(ioWriter http.ResponseWriter, ioRequest *http.Request) {
+
+		if ioRequest.Header.Get("Authorization") != "Bearer token" {
+			http.Error(ioWriter, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		loConn, loError := loUpgrader.Upgrade(ioWriter, ioRequest, nil)
+
+		if loError != nil {
+			return
+		}
+
+		rcConns <- loConn
+
+	}))
+
+	t.Cleanup(func() {
+		loServer.Close()
+		close(rcConns)
+		for loConn := range rcConns {
+			loConn.Close()
+		}
+	})
+
+	roClient = NewClient("token", WithStreamingURL("ws"+strings.TrimPrefix(loServer.URL, "http")))
+
+	return
+
+}
+
+// runStreamer runs the streamer until the test ends and checks how Run returned
+func runStreamer(t *testing.T, ioStreamer *Streamer) {
+
+	loContext, lfCancel := context.WithCancel(context.Background())
+
+	lcResult := make(chan error, 1)
+
+	go func() {
+		lcResult <- ioStreamer.Run(loContext)
+	}()
+
+	t.Cleanup(func() {
+
+		lfCancel()
+
+		select {
+		case loError := <-lcResult:
+			if !errors.Is(loError, context.Canceled) {
+				t.Errorf("run returned %v, want %v", loError, context.Canceled)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("run did not return after cancel")
+		}
+
+	})
+
+}
+
+func acceptStream(t *testing.T, icConns chan *websocket.Conn) (roConn *websocket.Conn) {
+
+	select {
+	case roConn = <-icConns:
+	case <-time.After(5 * time.Second):
+		t.Fatal("streamer did not connect")
+	}
+
+	return
+
+}
+
+func readStreamRequest(t *testing.T, ioConn *websocket.Conn) (rsRequest streamRequest) {
+
+	ioConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	if loError := ioConn.ReadJSON(&rsRequest); loError != nil {
+		t.Fatal(loError)
+	}
+
+	return
+
+}
+
+func TestStreamerDispatch(t *testing.T) {
+
+	loClient, lcConns := newStreamServer(t)
+
+	loStreamer := loClient.NewStreamer()
+
+	if loError := loStreamer.SubscribeCandles(FigiTCSG, IntervalMin1); loError != nil {
+		t.Fatal(loError)
+	}
+
+	runStreamer(t, loStreamer)
+
+	loConn := acceptStream(t, lcConns)
+
+	if lsRequest := readStreamRequest(t, loConn); lsRequest.Event != "candle:subscribe" || lsRequest.FIGI != FigiTCSG || lsRequest.Interval != IntervalMin1 {
+		t.Fatalf("request %+v, want the candle subscription", lsRequest)
+	}
+
+	if loError := loStreamer.SubscribeInstrumentInfo(FigiTCSG); loError != nil {
+		t.Fatal(loError)
+	}
+
+	if lsRequest := readStreamRequest(t, loConn); lsRequest.Event != "instrument_info:subscribe" {
+		t.Fatalf("request %+v, want the instrument info subscription", lsRequest)
+	}
+
+	for _, lvMessage := range []string{
+		`{"event":"candle","time":"2021-03-01T10:00:01Z","payload":{"o":3000,"c":3010,"h":3020,"l":2990,"v":15,"time":"2021-03-01T10:00:00Z","interval":"1min","figi":"` + FigiTCSG + `"}}`,
+		`{"event":"instrument_info","time":"2021-03-01T10:00:02Z","payload":{"figi":"` + FigiTCSG + `","trade_status":"normal_trading","min_price_increment":0.2,"lot":1}}`,
+		`{"event":"error","time":"2021-03-01T10:00:03Z","payload":{"error":"Subscription instrument_info:subscribe. FIGI NOPE not found","request_id":"1"}}`,
+	} {
+		if loError := loConn.WriteMessage(websocket.TextMessage, []byte(lvMessage)); loError != nil {
+			t.Fatal(loError)
+		}
+	}
+
+	select {
+	case lsEvent := <-loStreamer.Candles():
+		if lsEvent.FIGI != FigiTCSG || lsEvent.Interval != IntervalMin1 || lsEvent.Candle.Close != 3010 || lsEvent.Candle.Volume != 15 {
+			t.Errorf("candle %+v", lsEvent)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no candle")
+	}
+
+	select {
+	case lsInfo := <-loStreamer.InstrumentInfos():
+		if lsInfo.FIGI != FigiTCSG || lsInfo.TradeStatus != TradeStatusNormalTrading || lsInfo.MinPriceIncrement != 0.2 {
+			t.Errorf("instrument info %+v", lsInfo)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no instrument info")
+	}
+
+	select {
+	case loError := <-loStreamer.Errors():
+		loStreamError := &StreamError{}
+		if !errors.As(loError, &loStreamError) || loStreamError.RequestID != "1" {
+			t.Errorf("error %v, want the stream error", loError)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no error")
+	}
+
+}
+
+func TestStreamerResubscribe(t *testing.T) {
+
+	loClient, lcConns := newStreamServer(t)
+
+	loStreamer := loClient.NewStreamer(WithReconnectDelay(10*time.Millisecond, 10*time.Millisecond))
+
+	loStreamer.SubscribeCandles(FigiTCSG, IntervalMin1)
+	loStreamer.SubscribeOrderbook(FigiTCSG, 10)
+
+	runStreamer(t, loStreamer)
+
+	loConn := acceptStream(t, lcConns)
+
+	readStreamRequest(t, loConn)
+	readStreamRequest(t, loConn)
+
+	if loError := loStreamer.UnsubscribeOrderbook(FigiTCSG, 10); loError != nil {
+		t.Fatal(loError)
+	}
+
+	if lsRequest := readStreamRequest(t, loConn); lsRequest.Event != "orderbook:unsubscribe" {
+		t.Fatalf("request %+v, want the orderbook unsubscription", lsRequest)
+	}
+
+	// The server drops the connection, the streamer reconnects with the remaining subscription
+	loConn.Close()
+
+	loConn = acceptStream(t, lcConns)
+
+	if lsRequest := readStreamRequest(t, loConn); lsRequest.Event != "candle:subscribe" || lsRequest.FIGI != FigiTCSG {
+		t.Fatalf("request %+v, want the candle subscription", lsRequest)
+	}
+
+	loConn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+
+	if loError := loConn.ReadJSON(&streamRequest{}); loError == nil {
+		t.Error("the removed orderbook subscription was restored")
+	}
+
+}
+
+func TestStreamerKeepAlive(t *testing.T) {
+
+	loClient, lcConns := newStreamServer(t)
+
+	loStreamer := loClient.NewStreamer(WithPingInterval(50*time.Millisecond), WithReconnectDelay(10*time.Millisecond, 10*time.Millisecond))
+
+	runStreamer(t, loStreamer)
+
+	// Reading answers the pings, the connection stays for several intervals
+	loConn := acceptStream(t, lcConns)
+
+	lcClosed := make(chan struct{})
+
+	go func() {
+		defer close(lcClosed)
+		for {
+			if _, _, loError := loConn.ReadMessage(); loError != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-lcConns:
+		t.Fatal("reconnected although pongs were answered")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	loConn.Close()
+	<-lcClosed
+
+	// The new connection is not read, so no pong is sent and the streamer gives up after two intervals
+	acceptStream(t, lcConns)
+
+	lvConnected := time.Now()
+
+	acceptStream(t, lcConns)
+
+	if lvElapsed := time.Since(lvConnected); lvElapsed < 100*time.Millisecond {
+		t.Errorf("reconnected after %v, before the keepalive timeout", lvElapsed)
+	}
+
+	lvTimeout := false
+
+	for lvDone := false; !lvDone; {
+		select {
+		case loError := <-loStreamer.Errors():
+			loNetError, lvIsNet := loError.(net.Error)
+			lvTimeout = lvTimeout || lvIsNet && loNetError.Timeout()
+		default:
+			lvDone = true
+		}
+	}
+
+	if !lvTimeout {
+		t.Error("no timeout reported")
+	}
+
+}
+
+func TestStreamerRunOnce(t *testing.T) {
+
+	loClient, _ := newStreamServer(t)
+
+	loStreamer := loClient.NewStreamer()
+
+	loContext, lfCancel := context.WithCancel(context.Background())
+
+	lfCancel()
+
+	if loError := loStreamer.Run(loContext); !errors.Is(loError, context.Canceled) {
+		t.Fatalf("run returned %v, want %v", loError, context.Canceled)
+	}
+
+	if loError := loStreamer.Run(context.Background()); !errors.Is(loError, ErrStreamerUsed) {
+		t.Errorf("second run returned %v, want %v", loError, ErrStreamerUsed)
+	}
+
+}
@@ -0,0 +1,445 @@
+package tinvestclient
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	OrderEventNew             = "New"
+	OrderEventPartiallyFilled = "PartiallyFilled"
+	OrderEventFilled          = "Filled"
+	OrderEventCancelled       = "Cancelled"
+	OrderEventRejected        = "Rejected"
+	// OrderEventUnknown is sent for an order which left the active orders
+	// but has no operation yet, its executed lots are the last known ones
+	OrderEventUnknown = "Unknown"
+)
+
+type OrderEvent struct {
+	Type          string    `json:"type"`
+	OrderID       string    `json:"orderId"`
+	FIGI          string    `json:"figi"`
+	Operation     string    `json:"operation"`
+	Price         float64   `json:"price"`
+	RequestedLots int       `json:"requestedLots"`
+	ExecutedLots  int       `json:"executedLots"`
+	AveragePrice  float64   `json:"averagePrice"`
+	Time          time.Time `json:"time"`
+}
+
+// IsFinal reports whether no more events follow for the order
+func (e *OrderEvent) IsFinal() bool {
+
+	return e.Type == OrderEventFilled ||
+		e.Type == OrderEventCancelled ||
+		e.Type == OrderEventRejected
+
+}
+
+// OrderTrackerOption configures an OrderTracker created by Client.NewOrderTracker
+type OrderTrackerOption func(*OrderTracker)
+
+// WithPollInterval sets how often GetOrders and GetOperations are requested
+func WithPollInterval(ivInterval time.Duration) OrderTrackerOption {
+	return func(t *OrderTracker) {
+		t.mvPollInterval = ivInterval
+	}
+}
+
+// WithTrackTimeout limits how long a single order is tracked, zero means until the context is done
+func WithTrackTimeout(ivTimeout time.Duration) OrderTrackerOption {
+	return func(t *OrderTracker) {
+		t.mvTimeout = ivTimeout
+	}
+}
+
+// WithMissingPolls sets how many polls an order may be absent both from the
+// active orders and from the operations before it is reported as unknown.
+// Operations appear with a delay, so the order is tracked further until its
+// operation shows up or the tracking timeout expires.
+func WithMissingPolls(ivPolls int) OrderTrackerOption {
+	return func(t *OrderTracker) {
+		t.mvMissingPolls = ivPolls
+	}
+}
+
+// OrderTracker follows orders by polling the active orders and, once an
+// order leaves the list, by searching it in the operations. The streaming
+// API has no order events, so polling is the only source.
+type OrderTracker struct {
+	moClient       *Client
+	mvPollInterval time.Duration
+	mvTimeout      time.Duration
+	mvMissingPolls int
+	mvLookback     time.Duration
+}
+
+func (c *Client) NewOrderTracker(itOptions ...OrderTrackerOption) (roTracker *OrderTracker) {
+
+	roTracker = &OrderTracker{}
+
+	roTracker.moClient = c
+	roTracker.mvPollInterval = 2 * time.Second
+	roTracker.mvMissingPolls = 5
+	roTracker.mvLookback = time.Hour
+
+	for _, lfOption := range itOptions {
+		lfOption(roTracker)
+	}
+
+	return
+
+}
+
+// WaitForOrder blocks until the order is filled, cancelled or rejected
+func (c *Client) WaitForOrder(ioContext context.Context, ivOrderID string) (rsEvent OrderEvent, roError error) {
+
+	rsEvent, roError = c.NewOrderTracker().WaitForOrder(ioContext, ivOrderID)
+
+	return
+
+}
+
+// WaitForOrder blocks until the final event of the order. On timeout the last
+// known event is returned together with the context error, a request the API
+// rejects, e.g. for a wrong token, ends the wait with its error.
+func (t *OrderTracker) WaitForOrder(ioContext context.Context, ivOrderID string) (rsEvent OrderEvent, roError error) {
+
+	rsEvent, roError = t.waitForOrder(ioContext, newOrderTrackState(ivOrderID))
+
+	return
+
+}
+
+// waitForOrder continues from the state of an earlier wait, so an order
+// which was seen active is not lost while its operation is delayed
+func (t *OrderTracker) waitForOrder(ioContext context.Context, isState *orderTrackState) (rsEvent OrderEvent, roError error) {
+
+	lcEvents, lfErrors := t.track(ioContext, isState)
+
+	for lsEvent := range lcEvents {
+
+		rsEvent = lsEvent
+
+		if rsEvent.IsFinal() {
+			return
+		}
+
+	}
+
+	roError = lfErrors()
+
+	if roError == nil {
+		roError = fmt.Errorf("order %v: %w", isState.OrderID, context.Canceled)
+	}
+
+	return
+
+}
+
+// Track delivers the order events, the channel is closed after the final
+// event, a rejected request or when the context is done or the tracking
+// timeout expires
+func (t *OrderTracker) Track(ioContext context.Context, ivOrderID string) <-chan OrderEvent {
+
+	lcEvents, _ := t.track(ioContext, newOrderTrackState(ivOrderID))
+
+	return lcEvents
+
+}
+
+func (t *OrderTracker) track(ioContext context.Context, isState *orderTrackState) (rcEvents chan OrderEvent, rfError func() error) {
+
+	lcEvents := make(chan OrderEvent, 10)
+
+	var loError error
+
+	lcDone := make(chan struct{})
+
+	rcEvents = lcEvents
+
+	rfError = func() error {
+		<-lcDone
+		return loError
+	}
+
+	go func() {
+
+		defer close(lcDone)
+		defer close(lcEvents)
+
+		// The order was final already when it was placed
+		if isState.Last.IsFinal() {
+			lcEvents <- isState.Last
+			return
+		}
+
+		lfCancel := context.CancelFunc(func() {})
+
+		if t.mvTimeout > 0 {
+			ioContext, lfCancel = context.WithTimeout(ioContext, t.mvTimeout)
+		}
+
+		defer lfCancel()
+
+		loTicker := time.NewTicker(t.mvPollInterval)
+
+		defer loTicker.Stop()
+
+		for {
+
+			lsEvent, lvChanged, lvError := t.poll(ioContext, isState)
+
+			// Network errors and server failures pass, an error the API answers again does not
+			if lvError != nil && ioContext.Err() == nil && !isTransientError(lvError) {
+				loError = fmt.Errorf("order %v: %w", isState.OrderID, lvError)
+				return
+			}
+
+			if lvError == nil && lvChanged {
+
+				select {
+				case lcEvents <- lsEvent:
+				case <-ioContext.Done():
+				}
+
+				if lsEvent.IsFinal() {
+					return
+				}
+
+			}
+
+			select {
+			case <-loTicker.C:
+			case <-ioContext.Done():
+				loError = fmt.Errorf("order %v: %w", isState.OrderID, ioContext.Err())
+				return
+			}
+
+		}
+
+	}()
+
+	return
+
+}
+
+type orderTrackState struct {
+	OrderID   string
+	Started   time.Time
+	Last      OrderEvent
+	Seen      bool
+	Missing   int
+	FIGI      string
+	Operation string
+}
+
+func newOrderTrackState(ivOrderID string) (roState *orderTrackState) {
+
+	roState = &orderTrackState{}
+
+	roState.OrderID = ivOrderID
+	roState.Started = time.Now()
+
+	return
+
+}
+
+// newPlacedOrderTrackState starts from the order as the placement reported it,
+// an order filled at once is not searched in the operations
+func newPlacedOrderTrackState(isOrder PlacedOrder) (roState *orderTrackState) {
+
+	roState = newOrderTrackState(isOrder.ID)
+
+	roState.Seen = true
+	roState.FIGI = isOrder.FIGI
+	roState.Operation = isOrder.Operation
+
+	roState.Last.Type = orderEventType(isOrder.Status, isOrder.ExecutedLots)
+	roState.Last.OrderID = isOrder.ID
+	roState.Last.FIGI = isOrder.FIGI
+	roState.Last.Operation = isOrder.Operation
+	roState.Last.Price = isOrder.Price
+	roState.Last.RequestedLots = isOrder.RequestedLots
+	roState.Last.ExecutedLots = isOrder.ExecutedLots
+	roState.Last.Time = time.Now()
+
+	return
+
+}
+
+// orderEventType returns the event of an order in the status
+func orderEventType(ivStatus string, ivExecutedLots int) string {
+
+	switch {
+	case ivStatus == OrderStatusRejected:
+		return OrderEventRejected
+	case ivStatus == OrderStatusCancelled:
+		return OrderEventCancelled
+	case ivStatus == OrderStatusFill:
+		return OrderEventFilled
+	case ivExecutedLots > 0:
+		return OrderEventPartiallyFilled
+	default:
+		return OrderEventNew
+	}
+
+}
+
+// poll returns the current event of the order and whether it differs from the previous one
+func (t *OrderTracker) poll(ioContext context.Context, isState *orderTrackState) (rsEvent OrderEvent, rvChanged bool, roError error) {
+
+	ltOrders, roError := t.moClient.GetOrdersContext(ioContext)
+
+	if roError != nil {
+		return
+	}
+
+	rsEvent, rvChanged, roError = t.evaluate(ioContext, isState, ltOrders)
+
+	return
+
+}
+
+// evaluate derives the order event from the active orders and, if needed, from the operations
+func (t *OrderTracker) evaluate(ioContext context.Context, isState *orderTrackState, itOrders []Order) (rsEvent OrderEvent, rvChanged bool, roError error) {
+
+	for _, lsOrder := range itOrders {
+
+		if lsOrder.ID != isState.OrderID {
+			continue
+		}
+
+		isState.Seen = true
+		isState.Missing = 0
+		isState.FIGI = lsOrder.FIGI
+		isState.Operation = lsOrder.Operation
+
+		rsEvent = isState.Last
+
+		rsEvent.OrderID = lsOrder.ID
+		rsEvent.FIGI = lsOrder.FIGI
+		rsEvent.Operation = lsOrder.Operation
+		rsEvent.Price = lsOrder.Price
+		rsEvent.RequestedLots = lsOrder.RequestedLots
+		rsEvent.ExecutedLots = lsOrder.ExecutedLots
+		rsEvent.Type = orderEventType(lsOrder.Status, lsOrder.ExecutedLots)
+
+		rvChanged = t.update(isState, &rsEvent)
+
+		return
+
+	}
+
+	// The order is not active anymore, look for its operation
+	// Operations of a dual listing are requested and reported under its source FIGI
+	ltOperations, roError := t.moClient.getOperations(ioContext, t.moClient.operationsSource(isState.FIGI), isState.Started.Add(-t.mvLookback), time.Now().Add(time.Minute))
+
+	if roError != nil {
+		return
+	}
+
+	rsEvent = isState.Last
+
+	rsEvent.OrderID = isState.OrderID
+
+	for _, lsOperation := range ltOperations {
+
+		if lsOperation.ID != isState.OrderID {
+			continue
+		}
+
+		rsEvent.FIGI = t.moClient.operationFIGI(lsOperation.Figi, lsOperation.Currency)
+		rsEvent.Operation = lsOperation.OperationType
+
+		if rsEvent.Operation == OperationBuyCard {
+			rsEvent.Operation = OperationBuy
+		}
+
+		lvLot := 1
+
+		lsInstrument, loError := t.moClient.GetCachedInstrumentContext(ioContext, rsEvent.FIGI)
+
+		if loError == nil && lsInstrument.Lot > 0 {
+			lvLot = lsInstrument.Lot
+		}
+
+		lvQuantity := 0.0
+		lvValue := 0.0
+
+		for _, lsTrade := range lsOperation.Trades {
+			lvQuantity += lsTrade.Quantity
+			lvValue += lsTrade.Quantity * lsTrade.Price
+		}
+
+		if lvQuantity > 0 {
+			rsEvent.AveragePrice = lvValue / lvQuantity
+		} else {
+			rsEvent.AveragePrice = math.Abs(lsOperation.Price)
+		}
+
+		rsEvent.ExecutedLots = int(math.Round(lsOperation.QuantityExecuted / float64(lvLot)))
+
+		if rsEvent.RequestedLots == 0 {
+			rsEvent.RequestedLots = int(math.Round(lsOperation.Quantity / float64(lvLot)))
+		}
+
+		switch lsOperation.Status {
+
+		case OperationStatusDone:
+			if rsEvent.ExecutedLots < rsEvent.RequestedLots {
+				rsEvent.Type = OrderEventCancelled
+			} else {
+				rsEvent.Type = OrderEventFilled
+			}
+
+		case OperationStatusDecline:
+			if isState.Seen || rsEvent.ExecutedLots > 0 {
+				rsEvent.Type = OrderEventCancelled
+			} else {
+				rsEvent.Type = OrderEventRejected
+			}
+
+		default:
+			if rsEvent.ExecutedLots > 0 {
+				rsEvent.Type = OrderEventPartiallyFilled
+			} else {
+				rsEvent.Type = OrderEventNew
+			}
+
+		}
+
+		rvChanged = t.update(isState, &rsEvent)
+
+		return
+
+	}
+
+	isState.Missing++
+
+	// Filled or cancelled, the operation tells; until then the order is not final
+	if isState.Seen && isState.Missing >= t.mvMissingPolls {
+		rsEvent.Type = OrderEventUnknown
+		rvChanged = t.update(isState, &rsEvent)
+	}
+
+	return
+
+}
+
+func (t *OrderTracker) update(isState *orderTrackState, isEvent *OrderEvent) (rvChanged bool) {
+
+	rvChanged = isEvent.Type != isState.Last.Type ||
+		isEvent.ExecutedLots != isState.Last.ExecutedLots
+
+	if rvChanged {
+		isEvent.Time = time.Now()
+		isState.Last = *isEvent
+	}
+
+	return
+
+}
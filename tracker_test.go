@@ -0,0 +1,311 @@
+package tinvestclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testFIGI has no alias, its operations are reported under it
+const testFIGI = "BBG000B9XRY4"
+
+type testPlacement struct {
+	ID        string
+	FIGI      string
+	Type      string
+	Operation string
+	Lots      int
+	Price     float64
+}
+
+// testBroker is an OpenAPI stand-in keeping the active orders and the
+// operations, every instrument has a lot of one security
+type testBroker struct {
+	moMutex      sync.Mutex
+	mvStatus     int
+	mvNextID     int
+	mtPlacements []testPlacement
+	mtCancels    []string
+	mtOrders     []map[string]interface{}
+	mtOperations []map[string]interface{}
+	// mfPlace answers a placement, by default the order becomes active
+	mfPlace func(*testBroker, testPlacement) map[string]interface{}
+	// mfCancel cancels an active order, by default the executed lots are booked as an operation
+	mfCancel func(*testBroker, string)
+}
+
+func newTestBroker(t *testing.T) (roBroker *testBroker, roClient *Client) {
+
+	roBroker = &testBroker{}
+
+	loServer := httptest.NewServer(http.HandlerFunc(roBroker.serve))
+
+	t.Cleanup(loServer.Close)
+
+	roClient = NewClient("token", WithBaseURL(loServer.URL), WithoutRateLimit(), WithRetry(RetryPolicy{MaxAttempts: 1}))
+
+	return
+
+}
+
+func (b *testBroker) serve(ioWriter http.ResponseWriter, ioRequest *http.Request) {
+
+	b.moMutex.Lock()
+	defer b.moMutex.Unlock()
+
+	if b.mvStatus != 0 {
+		ioWriter.WriteHeader(b.mvStatus)
+		json.NewEncoder(ioWriter).Encode(map[string]interface{}{"trackingId": "test", "status": "Error", "payload": map[string]interface{}{"message": http.StatusText(b.mvStatus)}})
+		return
+	}
+
+	var lvPayload interface{}
+
+	lvFIGI := ioRequest.URL.Query().Get("figi")
+
+	switch ioRequest.URL.Path {
+
+	case "/orders":
+		lvPayload = append([]map[string]interface{}{}, b.mtOrders...)
+
+	case "/operations":
+		ltOperations := []map[string]interface{}{}
+		for _, lsOperation := range b.mtOperations {
+			if lvFIGI == "" || lsOperation["figi"] == lvFIGI {
+				ltOperations = append(ltOperations, lsOperation)
+			}
+		}
+		lvPayload = map[string]interface{}{"operations": ltOperations}
+
+	case "/market/orderbook":
+		lvPayload = map[string]interface{}{"figi": lvFIGI, "depth": 1, "bids": []OrderbookLevel{{Price: 2999, Quantity: 10}}, "asks": []OrderbookLevel{{Price: 3001, Quantity: 10}}}
+
+	case "/market/search/by-figi":
+		lvPayload = map[string]interface{}{"figi": lvFIGI, "lot": 1, "minPriceIncrement": 0.01}
+
+	case "/orders/limit-order", "/orders/market-order":
+		lsBody := struct {
+			Operation string  `json:"operation"`
+			Lots      int     `json:"lots"`
+			Price     float64 `json:"price"`
+		}{}
+		json.NewDecoder(ioRequest.Body).Decode(&lsBody)
+		b.mvNextID++
+		lsPlacement := testPlacement{ID: strconv.Itoa(b.mvNextID), FIGI: lvFIGI, Type: ioRequest.URL.Path[len("/orders/") : len(ioRequest.URL.Path)-len("-order")], Operation: lsBody.Operation, Lots: lsBody.Lots, Price: lsBody.Price}
+		b.mtPlacements = append(b.mtPlacements, lsPlacement)
+		if b.mfPlace != nil {
+			lvPayload = b.mfPlace(b, lsPlacement)
+		} else {
+			b.listOrder(lsPlacement, OrderStatusNew, 0)
+			lvPayload = placedPayload(lsPlacement, OrderStatusNew, 0)
+		}
+
+	case "/orders/cancel":
+		lvID := ioRequest.URL.Query().Get("orderId")
+		b.mtCancels = append(b.mtCancels, lvID)
+		if b.mfCancel != nil {
+			b.mfCancel(b, lvID)
+		} else {
+			b.cancelOrder(lvID)
+		}
+
+	default:
+		http.NotFound(ioWriter, ioRequest)
+		return
+
+	}
+
+	json.NewEncoder(ioWriter).Encode(map[string]interface{}{"trackingId": "test", "status": "Ok", "payload": lvPayload})
+
+}
+
+func placedPayload(isPlacement testPlacement, ivStatus string, ivExecuted int) map[string]interface{} {
+
+	return map[string]interface{}{"orderId": isPlacement.ID, "operation": isPlacement.Operation, "status": ivStatus, "requestedLots": isPlacement.Lots, "executedLots": ivExecuted}
+
+}
+
+// listOrder makes the order active, must be called with the mutex locked
+func (b *testBroker) listOrder(isPlacement testPlacement, ivStatus string, ivExecuted int) {
+
+	b.removeOrder(isPlacement.ID)
+
+	b.mtOrders = append(b.mtOrders, map[string]interface{}{"orderId": isPlacement.ID, "figi": isPlacement.FIGI, "operation": isPlacement.Operation,
+		"status": ivStatus, "requestedLots": isPlacement.Lots, "executedLots": ivExecuted, "type": isPlacement.Type, "price": isPlacement.Price})
+
+}
+
+// removeOrder returns the active order and removes it, must be called with the mutex locked
+func (b *testBroker) removeOrder(ivID string) (rsOrder map[string]interface{}) {
+
+	for lvIndex, lsOrder := range b.mtOrders {
+		if lsOrder["orderId"] == ivID {
+			b.mtOrders = append(b.mtOrders[:lvIndex], b.mtOrders[lvIndex+1:]...)
+			rsOrder = lsOrder
+			return
+		}
+	}
+
+	return
+
+}
+
+// addOperation books the order, must be called with the mutex locked
+func (b *testBroker) addOperation(ivID string, ivFIGI string, ivOperation string, ivRequested int, ivExecuted int, ivPrice float64) {
+
+	lvStatus := OperationStatusDone
+
+	if ivExecuted == 0 {
+		lvStatus = OperationStatusDecline
+	}
+
+	lsOperation := map[string]interface{}{"id": ivID, "status": lvStatus, "figi": ivFIGI, "currency": CurrencyRUB, "operationType": ivOperation,
+		"quantity": ivRequested, "quantityExecuted": ivExecuted, "price": ivPrice, "date": time.Now()}
+
+	if ivExecuted > 0 {
+		lsOperation["trades"] = []map[string]interface{}{{"tradeId": "t" + ivID, "date": time.Now(), "price": ivPrice, "quantity": ivExecuted}}
+	}
+
+	b.mtOperations = append(b.mtOperations, lsOperation)
+
+}
+
+// cancelOrder removes the active order and books its executed lots, must be called with the mutex locked
+func (b *testBroker) cancelOrder(ivID string) {
+
+	lsOrder := b.removeOrder(ivID)
+
+	if lsOrder == nil {
+		return
+	}
+
+	b.addOperation(ivID, lsOrder["figi"].(string), lsOrder["operation"].(string), lsOrder["requestedLots"].(int), lsOrder["executedLots"].(int), lsOrder["price"].(float64))
+
+}
+
+// fill executes the lots of an active order, all lots remove it and book the operation
+func (b *testBroker) fill(ivID string, ivExecuted int) {
+
+	b.moMutex.Lock()
+	defer b.moMutex.Unlock()
+
+	for _, lsOrder := range b.mtOrders {
+
+		if lsOrder["orderId"] != ivID {
+			continue
+		}
+
+		lsOrder["executedLots"] = ivExecuted
+		lsOrder["status"] = OrderStatusPartiallyFill
+
+		if ivExecuted >= lsOrder["requestedLots"].(int) {
+			b.cancelOrder(ivID)
+		}
+
+		return
+
+	}
+
+}
+
+func (b *testBroker) placements() []testPlacement {
+
+	b.moMutex.Lock()
+	defer b.moMutex.Unlock()
+
+	return append([]testPlacement(nil), b.mtPlacements...)
+
+}
+
+func (b *testBroker) placedLots() (rvLots int) {
+
+	for _, lsPlacement := range b.placements() {
+		rvLots += lsPlacement.Lots
+	}
+
+	return
+
+}
+
+func TestTrackerUnauthorized(t *testing.T) {
+
+	loBroker, loClient := newTestBroker(t)
+
+	loBroker.mvStatus = http.StatusUnauthorized
+
+	loContext, lfCancel := context.WithTimeout(context.Background(), 5*time.Second)
+
+	defer lfCancel()
+
+	_, loError := loClient.NewOrderTracker(WithPollInterval(10*time.Millisecond)).WaitForOrder(loContext, "x")
+
+	if !errors.Is(loError, ErrUnauthorized) {
+		t.Errorf("error %v, want %v", loError, ErrUnauthorized)
+	}
+
+	if loContext.Err() != nil {
+		t.Error("wait ended by the deadline")
+	}
+
+}
+
+func TestTrackerMissingOrderUnknown(t *testing.T) {
+
+	loBroker, loClient := newTestBroker(t)
+
+	lsPlacement := testPlacement{ID: "1", FIGI: testFIGI, Type: orderTypeLimit, Operation: OperationBuy, Lots: 2, Price: 3000}
+
+	loBroker.listOrder(lsPlacement, OrderStatusPartiallyFill, 1)
+
+	loTracker := loClient.NewOrderTracker(WithPollInterval(10*time.Millisecond), WithMissingPolls(2), WithTrackTimeout(5*time.Second))
+
+	lcEvents := loTracker.Track(context.Background(), "1")
+
+	if lsEvent := <-lcEvents; lsEvent.Type != OrderEventPartiallyFilled || lsEvent.ExecutedLots != 1 {
+		t.Fatalf("event %+v, want partially filled", lsEvent)
+	}
+
+	// The order is filled, but its operation is late
+	loBroker.moMutex.Lock()
+	loBroker.removeOrder("1")
+	loBroker.moMutex.Unlock()
+
+	if lsEvent := <-lcEvents; lsEvent.Type != OrderEventUnknown || lsEvent.ExecutedLots != 1 {
+		t.Fatalf("event %+v, want unknown with the last known lots", lsEvent)
+	}
+
+	loBroker.moMutex.Lock()
+	loBroker.addOperation("1", testFIGI, OperationBuy, 2, 2, 3000)
+	loBroker.moMutex.Unlock()
+
+	if lsEvent := <-lcEvents; lsEvent.Type != OrderEventFilled || lsEvent.ExecutedLots != 2 || lsEvent.AveragePrice != 3000 {
+		t.Fatalf("event %+v, want filled", lsEvent)
+	}
+
+	if _, lvOpen := <-lcEvents; lvOpen {
+		t.Error("events after the final one")
+	}
+
+}
+
+func TestTrackerPlacedFilled(t *testing.T) {
+
+	loBroker, loClient := newTestBroker(t)
+
+	loBroker.mvStatus = http.StatusInternalServerError
+
+	lsState := newPlacedOrderTrackState(PlacedOrder{ID: "1", FIGI: testFIGI, Operation: OperationBuy, Status: OrderStatusFill, RequestedLots: 2, ExecutedLots: 2})
+
+	lsEvent, loError := loClient.NewOrderTracker().waitForOrder(context.Background(), lsState)
+
+	if loError != nil || lsEvent.Type != OrderEventFilled || lsEvent.ExecutedLots != 2 {
+		t.Errorf("event %+v, %v, want filled without polling", lsEvent, loError)
+	}
+
+}
@@ -0,0 +1,99 @@
+package tinvestclient
+
+import (
+	"fmt"
+	"math"
+)
+
+// AddTrailingStop protects a long position: the whole position is sold with a
+// market order once the price retraces from its high by the distance or percent
+func (e *StopEngine) AddTrailingStop(isPosition Position, ivDistance float64, ivPercent float64) (rsStop StopOrder, roError error) {
+
+	if isPosition.Lots <= 0 {
+		roError = fmt.Errorf("position %v has no lots to protect", isPosition.FIGI)
+		return
+	}
+
+	lsStop := StopOrder{}
+
+	lsStop.Type = StopTypeTrailing
+	lsStop.FIGI = isPosition.FIGI
+	lsStop.Operation = OperationSell
+	lsStop.Lots = isPosition.Lots
+	lsStop.TrailDistance = ivDistance
+	lsStop.TrailPercent = ivPercent
+
+	rsStop, roError = e.AddStop(lsStop)
+
+	return
+
+}
+
+// ModifyTrailingStop changes the trail of a pending trailing stop, the trigger follows on the next price
+func (e *StopEngine) ModifyTrailingStop(ivID string, ivDistance float64, ivPercent float64) (rsStop StopOrder, roError error) {
+
+	roError = e.updateStop(ivID, func(isStop *StopOrder) {
+		isStop.TrailDistance = ivDistance
+		isStop.TrailPercent = ivPercent
+		if isStop.Type == StopTypeTrailing && isStop.Extremum > 0 {
+			isStop.TriggerPrice = trailingTrigger(isStop)
+		}
+	})
+
+	if roError != nil {
+		return
+	}
+
+	rsStop, roError = e.GetStop(ivID)
+
+	return
+
+}
+
+// trail moves the extremum and the trigger of a trailing stop, must be called with the mutex locked
+func (e *StopEngine) trail(isStop *StopOrder, ivPrice float64) (rvChanged bool) {
+
+	// A sell stop follows the highs, a buy stop follows the lows
+	if isStop.Extremum > 0 {
+		if isStop.Operation == OperationSell && ivPrice <= isStop.Extremum {
+			return
+		}
+		if isStop.Operation == OperationBuy && ivPrice >= isStop.Extremum {
+			return
+		}
+	}
+
+	isStop.Extremum = ivPrice
+
+	lvTrigger := trailingTrigger(isStop)
+
+	if lvTrigger == isStop.TriggerPrice {
+		return
+	}
+
+	e.logf("stop %v trailed: extremum %v, trigger %v -> %v", isStop.ID, isStop.Extremum, isStop.TriggerPrice, lvTrigger)
+
+	isStop.TriggerPrice = lvTrigger
+	rvChanged = true
+
+	return
+
+}
+
+func trailingTrigger(isStop *StopOrder) (rvTrigger float64) {
+
+	lvDistance := isStop.TrailDistance
+
+	if isStop.TrailPercent > 0 {
+		lvDistance = isStop.Extremum * isStop.TrailPercent / 100
+	}
+
+	if isStop.Operation == OperationSell {
+		rvTrigger = math.Max(isStop.Extremum-lvDistance, 0)
+	} else {
+		rvTrigger = isStop.Extremum + lvDistance
+	}
+
+	return
+
+}
@@ -0,0 +1,52 @@
+package tinvestclient
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestStopEngineDefaultLogger(t *testing.T) {
+
+	loEngine, loError := NewStopEngine(nil, nil)
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	if loEngine.moLogger != log.Default() {
+		t.Error("stop engine does not log by default")
+	}
+
+}
+
+func TestTrailingStopLogged(t *testing.T) {
+
+	lsBuffer := bytes.Buffer{}
+
+	loEngine, loError := NewStopEngine(nil, nil, WithStopLogger(log.New(&lsBuffer, "", 0)))
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	lsStop, loError := loEngine.AddTrailingStop(Position{FIGI: testFIGI, Lots: 2}, 10, 0)
+
+	if loError != nil {
+		t.Fatal(loError)
+	}
+
+	loEngine.OnPrice(context.Background(), testFIGI, 100)
+	loEngine.OnPrice(context.Background(), testFIGI, 110)
+
+	if lsStop, loError = loEngine.GetStop(lsStop.ID); loError != nil || lsStop.TriggerPrice != 100 {
+		t.Fatalf("stop %+v, %v, want trigger 100", lsStop, loError)
+	}
+
+	if lvCount := strings.Count(lsBuffer.String(), "stop "+lsStop.ID+" trailed"); lvCount != 2 {
+		t.Errorf("%v adjustments logged, want 2:\n%v", lvCount, lsBuffer.String())
+	}
+
+}
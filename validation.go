@@ -0,0 +1,273 @@
+package tinvestclient
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PriceRoundingReject  = "reject"
+	PriceRoundingNearest = "nearest"
+)
+
+// OrderValidation enables checks of orders before they are sent to the broker
+type OrderValidation struct {
+	// PriceRounding defines what to do with a limit price which is not a
+	// multiple of MinPriceIncrement, PriceRoundingReject by default
+	PriceRounding string
+	// CheckPriceBands rejects limit prices outside of the orderbook limit
+	// up/down bands and orders for instruments which are not trading
+	CheckPriceBands bool
+	// InstrumentTTL is the lifetime of cached instrument data, zero means forever
+	InstrumentTTL time.Duration
+	// PriceBandsTTL is the lifetime of cached price bands, one minute by default
+	PriceBandsTTL time.Duration
+}
+
+// OrderValidationError describes an order rejected by the client-side checks
+type OrderValidationError struct {
+	FIGI   string
+	Field  string
+	Reason string
+}
+
+func (e *OrderValidationError) Error() string {
+
+	return fmt.Sprintf("invalid order for %v: %v %v", e.FIGI, e.Field, e.Reason)
+
+}
+
+func (e *OrderValidationError) Is(ioTarget error) bool {
+
+	return ioTarget == ErrInvalidOrder
+
+}
+
+type instrumentCache struct {
+	moMutex       sync.RWMutex
+	mtInstruments map[string]instrumentCacheEntry
+	mtOrderbooks  map[string]orderbookCacheEntry
+}
+
+type instrumentCacheEntry struct {
+	Instrument Instrument
+	Time       time.Time
+}
+
+type orderbookCacheEntry struct {
+	Orderbook Orderbook
+	Time      time.Time
+}
+
+func newInstrumentCache() *instrumentCache {
+
+	return &instrumentCache{
+		mtInstruments: map[string]instrumentCacheEntry{},
+		mtOrderbooks:  map[string]orderbookCacheEntry{},
+	}
+
+}
+
+func (c *Client) GetCachedInstrument(ivFIGI string) (rsInstrument Instrument, roError error) {
+
+	rsInstrument, roError = c.GetCachedInstrumentContext(context.Background(), ivFIGI)
+
+	return
+
+}
+
+// GetCachedInstrumentContext returns the instrument by FIGI, requesting it once per cache lifetime
+func (c *Client) GetCachedInstrumentContext(ioContext context.Context, ivFIGI string) (rsInstrument Instrument, roError error) {
+
+	lvTTL := time.Duration(0)
+
+	if c.moValidation != nil {
+		lvTTL = c.moValidation.InstrumentTTL
+	}
+
+	if c.moInstruments == nil {
+		rsInstrument, roError = c.GetInstrumentByFIGIContext(ioContext, ivFIGI)
+		return
+	}
+
+	c.moInstruments.moMutex.RLock()
+
+	lsEntry, lvFound := c.moInstruments.mtInstruments[ivFIGI]
+
+	c.moInstruments.moMutex.RUnlock()
+
+	if lvFound && (lvTTL == 0 || time.Since(lsEntry.Time) < lvTTL) {
+		rsInstrument = lsEntry.Instrument
+		return
+	}
+
+	rsInstrument, roError = c.GetInstrumentByFIGIContext(ioContext, ivFIGI)
+
+	if roError != nil {
+		return
+	}
+
+	c.moInstruments.moMutex.Lock()
+
+	c.moInstruments.mtInstruments[ivFIGI] = instrumentCacheEntry{Instrument: rsInstrument, Time: time.Now()}
+
+	c.moInstruments.moMutex.Unlock()
+
+	return
+
+}
+
+func (c *Client) getCachedPriceBands(ioContext context.Context, ivFIGI string) (rsOrderbook Orderbook, roError error) {
+
+	lvTTL := time.Minute
+
+	if c.moValidation != nil && c.moValidation.PriceBandsTTL > 0 {
+		lvTTL = c.moValidation.PriceBandsTTL
+	}
+
+	if c.moInstruments == nil {
+		rsOrderbook, roError = c.GetOrderbookContext(ioContext, ivFIGI, 1)
+		return
+	}
+
+	c.moInstruments.moMutex.RLock()
+
+	lsEntry, lvFound := c.moInstruments.mtOrderbooks[ivFIGI]
+
+	c.moInstruments.moMutex.RUnlock()
+
+	if lvFound && time.Since(lsEntry.Time) < lvTTL {
+		rsOrderbook = lsEntry.Orderbook
+		return
+	}
+
+	rsOrderbook, roError = c.GetOrderbookContext(ioContext, ivFIGI, 1)
+
+	if roError != nil {
+		return
+	}
+
+	c.moInstruments.moMutex.Lock()
+
+	c.moInstruments.mtOrderbooks[ivFIGI] = orderbookCacheEntry{Orderbook: rsOrderbook, Time: time.Now()}
+
+	c.moInstruments.moMutex.Unlock()
+
+	return
+
+}
+
+// validateOrder checks the order and returns the price to send
+func (c *Client) validateOrder(ioContext context.Context, ivType string, ivFIGI string, ivOperation string, ivLots int, ivPrice float64) (rvPrice float64, roError error) {
+
+	rvPrice = ivPrice
+
+	if ivOperation != OperationBuy && ivOperation != OperationSell {
+		roError = &OrderValidationError{FIGI: ivFIGI, Field: "operation", Reason: fmt.Sprintf("%q is not supported", ivOperation)}
+		return
+	}
+
+	if ivLots <= 0 {
+		roError = &OrderValidationError{FIGI: ivFIGI, Field: "lots", Reason: fmt.Sprintf("%v must be positive", ivLots)}
+		return
+	}
+
+	if ivType == orderTypeLimit {
+
+		if ivPrice <= 0 {
+			roError = &OrderValidationError{FIGI: ivFIGI, Field: "price", Reason: fmt.Sprintf("%v must be positive", ivPrice)}
+			return
+		}
+
+		lsInstrument, loError := c.GetCachedInstrumentContext(ioContext, ivFIGI)
+
+		if loError != nil {
+			roError = loError
+			return
+		}
+
+		if lsInstrument.MinPriceIncrement > 0 {
+
+			lvRounded := RoundPrice(ivPrice, lsInstrument.MinPriceIncrement)
+
+			if !isSamePrice(lvRounded, ivPrice) && c.moValidation.PriceRounding != PriceRoundingNearest {
+				roError = &OrderValidationError{FIGI: ivFIGI, Field: "price", Reason: fmt.Sprintf("%v is not a multiple of %v", ivPrice, lsInstrument.MinPriceIncrement)}
+				return
+			}
+
+			rvPrice = lvRounded
+
+		}
+
+	}
+
+	if !c.moValidation.CheckPriceBands {
+		return
+	}
+
+	lsOrderbook, roError := c.getCachedPriceBands(ioContext, ivFIGI)
+
+	if roError != nil {
+		return
+	}
+
+	if lsOrderbook.TradeStatus != "" && !lsOrderbook.IsTrading() {
+		roError = &OrderValidationError{FIGI: ivFIGI, Field: "instrument", Reason: fmt.Sprintf("is not trading (%v)", lsOrderbook.TradeStatus)}
+		return
+	}
+
+	if ivType != orderTypeLimit {
+		return
+	}
+
+	if lsOrderbook.LimitUp > 0 && rvPrice > lsOrderbook.LimitUp {
+		roError = &OrderValidationError{FIGI: ivFIGI, Field: "price", Reason: fmt.Sprintf("%v is above limit up %v", rvPrice, lsOrderbook.LimitUp)}
+		return
+	}
+
+	if lsOrderbook.LimitDown > 0 && rvPrice < lsOrderbook.LimitDown {
+		roError = &OrderValidationError{FIGI: ivFIGI, Field: "price", Reason: fmt.Sprintf("%v is below limit down %v", rvPrice, lsOrderbook.LimitDown)}
+		return
+	}
+
+	return
+
+}
+
+// RoundPrice rounds the price to the nearest multiple of the increment
+func RoundPrice(ivPrice float64, ivIncrement float64) (rvPrice float64) {
+
+	if ivIncrement <= 0 {
+		rvPrice = ivPrice
+		return
+	}
+
+	rvPrice = math.Round(ivPrice/ivIncrement) * ivIncrement
+
+	// Remove the binary noise like 0.30000000000000004
+	lvDecimals := 0
+
+	lvIncrement := strconv.FormatFloat(ivIncrement, 'f', -1, 64)
+
+	if lvIndex := strings.IndexByte(lvIncrement, '.'); lvIndex >= 0 {
+		lvDecimals = len(lvIncrement) - lvIndex - 1
+	}
+
+	lvFactor := math.Pow(10, float64(lvDecimals))
+
+	rvPrice = math.Round(rvPrice*lvFactor) / lvFactor
+
+	return
+
+}
+
+func isSamePrice(ivPrice1 float64, ivPrice2 float64) bool {
+
+	return math.Abs(ivPrice1-ivPrice2) < 1e-9
+
+}